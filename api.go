@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultJobHistory = 50
+	maxHistory        = 1000
+)
+
+// JobView is a job as the status API lists it: its state, its last result
+// and its config. It is built from the scheduler alone, so listing jobs
+// never reads the result store.
+type JobView struct {
+	JobStatus
+
+	Config CheckConfig `json:"config"`
+}
+
+// JobDetail is a single job with its latest results, oldest first.
+type JobDetail struct {
+	JobView
+
+	History []ResultRecord `json:"history"`
+}
+
+// DomainView is a configured domain with its jobs.
+type DomainView struct {
+	Config Domain    `json:"config"`
+	Jobs   []JobView `json:"jobs"`
+}
+
+// PluginView lists a loaded plugin and the checks it registered.
+type PluginView struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Checks []string `json:"checks"`
+}
+
+// StatusServer serves the read-only part of the control API over TCP, so
+// other tools can read the current state without access to the control
+// socket.
+type StatusServer struct {
+	log *slog.Logger
+
+	addr     string
+	listener net.Listener
+	server   *http.Server
+}
+
+func NewStatusServer(log *slog.Logger, handler http.Handler, addr string) *StatusServer {
+	log = log.With("service", "Status")
+
+	return &StatusServer{
+		log: log,
+
+		addr: addr,
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *StatusServer) Listen() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.listener = listener
+
+	s.log.Info("Status API listening", "addr", listener.Addr())
+
+	return nil
+}
+
+func (s *StatusServer) Serve() {
+	err := s.server.Serve(s.listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.log.Error("Status server failed", "error", err)
+	}
+}
+
+func (s *StatusServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.log.Warn("Status server shutdown failed", "error", err)
+	}
+}
+
+// historyParam reads the number of results to include from the history
+// query parameter.
+func historyParam(r *http.Request, fallback int) (int, error) {
+	value := r.URL.Query().Get("history")
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > maxHistory {
+		return 0, fmt.Errorf("history must be a number between 0 and %d", maxHistory)
+	}
+
+	return n, nil
+}
+
+func newJobView(status JobStatus) JobView {
+	return JobView{
+		JobStatus: status,
+		Config:    status.config,
+	}
+}
+
+func (c *ControlServer) jobViews(w http.ResponseWriter) ([]JobView, bool) {
+	status, ok := c.scheduler.Status(time.Now())
+	if !ok {
+		writeError(w, errNotRunning)
+
+		return nil, false
+	}
+
+	views := []JobView{}
+	for _, job := range status.Jobs {
+		views = append(views, newJobView(job))
+	}
+
+	return views, true
+}
+
+func (c *ControlServer) listDomains(w http.ResponseWriter, r *http.Request) {
+	views, ok := c.jobViews(w)
+	if !ok {
+		return
+	}
+
+	domains := []DomainView{}
+	byDomain := map[string]int{}
+
+	for _, view := range views {
+		i, ok := byDomain[view.Domain]
+		if !ok {
+			i = len(domains)
+			byDomain[view.Domain] = i
+
+			domains = append(domains, DomainView{
+				Config: view.domain,
+				Jobs:   []JobView{},
+			})
+		}
+
+		domains[i].Jobs = append(domains[i].Jobs, view)
+	}
+
+	writeJSON(w, http.StatusOK, domains)
+}
+
+func (c *ControlServer) listJobs(w http.ResponseWriter, r *http.Request) {
+	views, ok := c.jobViews(w)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (c *ControlServer) getJob(w http.ResponseWriter, r *http.Request) {
+	history, err := historyParam(r, defaultJobHistory)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	status, ok := c.scheduler.Status(time.Now())
+	if !ok {
+		writeError(w, errNotRunning)
+
+		return
+	}
+
+	key := r.PathValue("domain") + "/" + r.PathValue("check")
+
+	i := slices.IndexFunc(status.Jobs, func(job JobStatus) bool {
+		return job.Job == key
+	})
+	if i < 0 {
+		writeError(w, fmt.Errorf("job %s: %w", key, errNotFound))
+
+		return
+	}
+
+	detail := JobDetail{
+		JobView: newJobView(status.Jobs[i]),
+		History: []ResultRecord{},
+	}
+
+	if history > 0 {
+		detail.History, err = c.results.Tail(key, history)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (c *ControlServer) listPlugins(w http.ResponseWriter, r *http.Request) {
+	plugins := []PluginView{}
+
+	for _, plugin := range c.app.plugins {
+		view := PluginView{
+			ID:     plugin.Id(),
+			Name:   plugin.Name(),
+			Checks: []string{},
+		}
+
+		for key := range c.app.checks[plugin.Id()] {
+			view.Checks = append(view.Checks, key)
+		}
+
+		slices.Sort(view.Checks)
+
+		plugins = append(plugins, view)
+	}
+
+	writeJSON(w, http.StatusOK, plugins)
+}
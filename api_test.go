@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tailCountingStore counts the Tail calls on a memoryStore.
+type tailCountingStore struct {
+	*memoryStore
+
+	mu    sync.Mutex
+	tails int
+}
+
+func (s *tailCountingStore) Tail(job string, n int) ([]ResultRecord, error) {
+	s.mu.Lock()
+	s.tails++
+	s.mu.Unlock()
+
+	records, err := s.Query(job, time.Time{}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return records[max(len(records)-n, 0):], nil
+}
+
+func (s *tailCountingStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tails
+}
+
+type testPlugin struct{}
+
+func (testPlugin) Id() string {
+	return "test"
+}
+
+func (testPlugin) Name() string {
+	return "Test Plugin"
+}
+
+func (testPlugin) Setup(ctx *PluginCtx) error {
+	for _, key := range []string{"http", "dns"} {
+		ctx.AddCheck(Check{
+			Key:  key,
+			Name: key,
+			RunContext: func(context.Context, string, map[string]string) CheckResult {
+				return CheckResult{Success: true}
+			},
+		})
+	}
+
+	return nil
+}
+
+func (testPlugin) Shutdown(*PluginCtx) error {
+	return nil
+}
+
+// testStatusServer runs a scheduler with two jobs on example.com and one on
+// example.org, and serves its read-only API. The jobs have run once and are
+// not due again during the test.
+func testStatusServer(t *testing.T) (*httptest.Server, *tailCountingStore) {
+	t.Helper()
+
+	now := time.Now()
+	store := &tailCountingStore{memoryStore: &memoryStore{}}
+
+	jobs := []*Job{}
+	for _, key := range []string{"example.com/http", "example.com/dns", "example.org/http"} {
+		domain, check, _ := splitJob(key)
+
+		job, err := NewJob(Domain{Domain: domain}, Check{Name: check}, CheckConfig{Key: check, Interval: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	s := testScheduler(t, Config{}, store, jobs...)
+
+	// The scheduler read the empty store to restore the jobs, only count
+	// what the API reads.
+	store.tails = 0
+
+	for _, job := range jobs {
+		for i := 0; i < 3; i++ {
+			store.records = append(store.records, ResultRecord{Job: job.Key(), At: now.Add(time.Duration(i-3) * time.Minute), Success: true})
+		}
+
+		job.lastRun = now.Add(-time.Minute)
+		job.lastResult = CheckResult{Success: true, Message: "ok", Metrics: map[string]float64{"status_code": 200}}
+		job.next = now.Add(time.Hour)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		s.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	app := NewApp(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err := app.AddPlugin(testPlugin{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	control := NewControlServer(slog.New(slog.NewTextHandler(io.Discard, nil)), app, s, s.dispatcher, s.incidents, store, "")
+
+	server := httptest.NewServer(control.ReadOnlyHandler())
+	t.Cleanup(server.Close)
+
+	return server, store
+}
+
+func getJSON(t *testing.T, url string, status int, value any) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: got %s, want %d", url, resp.Status, status)
+	}
+
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("GET %s: got content type %q", url, resp.Header.Get("Content-Type"))
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkFields fails unless object has exactly the wanted top level fields.
+func checkFields(t *testing.T, name string, object map[string]json.RawMessage, fields ...string) {
+	t.Helper()
+
+	for _, field := range fields {
+		if _, ok := object[field]; !ok {
+			t.Fatalf("%s has no field %s: %v", name, field, object)
+		}
+	}
+
+	if len(object) != len(fields) {
+		keys := []string{}
+		for key := range object {
+			keys = append(keys, key)
+		}
+
+		t.Fatalf("%s has fields %v, want %v", name, keys, fields)
+	}
+}
+
+var jobViewFields = []string{
+	"job", "domain", "check", "check_name", "state", "state_since",
+	"consecutive_successes", "consecutive_failures", "last_result", "next_run",
+	"running", "suppressed", "quarantined", "config",
+}
+
+func TestStatusAPIListsWithoutReadingResults(t *testing.T) {
+	server, store := testStatusServer(t)
+
+	jobs := []map[string]json.RawMessage{}
+	getJSON(t, server.URL+"/api/v1/jobs", http.StatusOK, &jobs)
+
+	if len(jobs) != 3 {
+		t.Fatalf("got %d jobs, want 3", len(jobs))
+	}
+
+	checkFields(t, "job", jobs[0], jobViewFields...)
+
+	var last ResultRecord
+
+	err := json.Unmarshal(jobs[0]["last_result"], &last)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last.Job != "example.com/http" || !last.Success || last.Message != "ok" || last.Metrics["status_code"] != 200 {
+		t.Fatalf("got last result %+v", last)
+	}
+
+	domains := []struct {
+		Config Domain                       `json:"config"`
+		Jobs   []map[string]json.RawMessage `json:"jobs"`
+	}{}
+	getJSON(t, server.URL+"/api/v1/domains", http.StatusOK, &domains)
+
+	if len(domains) != 2 || domains[0].Config.Domain != "example.com" || len(domains[0].Jobs) != 2 || len(domains[1].Jobs) != 1 {
+		t.Fatalf("got domains %+v", domains)
+	}
+
+	checkFields(t, "domain job", domains[1].Jobs[0], jobViewFields...)
+
+	if store.count() != 0 {
+		t.Fatalf("listing read the result store %d times", store.count())
+	}
+}
+
+func TestStatusAPIJob(t *testing.T) {
+	server, store := testStatusServer(t)
+
+	job := map[string]json.RawMessage{}
+	getJSON(t, server.URL+"/api/v1/jobs/example.com/dns?history=2", http.StatusOK, &job)
+
+	checkFields(t, "job", job, append(jobViewFields, "history")...)
+
+	history := []ResultRecord{}
+
+	err := json.Unmarshal(job["history"], &history)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 2 || history[0].Job != "example.com/dns" || !history[0].At.Before(history[1].At) {
+		t.Fatalf("got history %+v", history)
+	}
+
+	if store.count() != 1 {
+		t.Fatalf("got %d reads of the result store, want 1", store.count())
+	}
+
+	apiErr := apiError{}
+	getJSON(t, server.URL+"/api/v1/jobs/example.com/missing", http.StatusNotFound, &apiErr)
+	getJSON(t, server.URL+"/api/v1/jobs/example.com/dns?history=-1", http.StatusBadRequest, &apiErr)
+
+	plugins := []PluginView{}
+	getJSON(t, server.URL+"/api/v1/plugins", http.StatusOK, &plugins)
+
+	if len(plugins) != 1 || plugins[0].ID != "test" || len(plugins[0].Checks) != 2 || plugins[0].Checks[0] != "dns" {
+		t.Fatalf("got plugins %+v", plugins)
+	}
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ControlClient talks to the control API of a running instance.
+type ControlClient struct {
+	http *http.Client
+}
+
+func NewControlClient(path string) *ControlClient {
+	return &ControlClient{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+func (c *ControlClient) Do(method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://uptime-gopher"+path, reader)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		var apiErr apiError
+
+		err := json.NewDecoder(res.Body).Decode(&apiErr)
+		if err != nil || apiErr.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, res.Status)
+		}
+
+		return fmt.Errorf("%s", apiErr.Error)
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+
+	return nil
+}
+
+// controlSocket returns the socket from the flag, or from the config file if
+// the flag is empty.
+func controlSocket(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	config, err := LoadConfig(configPath)
+	if err == nil && config.ControlSocket != "" {
+		return config.ControlSocket
+	}
+
+	return defaultControlSocket
+}
+
+// splitJob splits a job key at its last slash, since domains may contain
+// slashes but check keys don't.
+func splitJob(job string) (string, string, error) {
+	i := strings.LastIndex(job, "/")
+	if i <= 0 || i == len(job)-1 {
+		return "", "", fmt.Errorf("job must be <domain>/<check>")
+	}
+
+	return job[:i], job[i+1:], nil
+}
+
+func currentUser() string {
+	current, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+
+	return current.Username
+}
+
+// runCommand runs a CLI subcommand against a running instance and returns
+// the exit code.
+func runCommand(args []string) int {
+	var err error
+
+	switch args[0] {
+	case "maintenance":
+		err = maintenanceCommand(args[1:])
+	case "escalations":
+		err = escalationsCommand(args[1:])
+	case "ack":
+		err = ackCommand(args[1:])
+	case "incidents":
+		err = incidentsCommand(args[1:])
+	case "history":
+		err = historyCommand(args[1:])
+	case "sla":
+		err = slaCommand(args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func maintenanceCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: maintenance list|add|remove [flags]")
+	}
+
+	flags := flag.NewFlagSet("maintenance "+args[0], flag.ContinueOnError)
+	socket := flags.String("socket", "", "control socket path")
+
+	switch args[0] {
+	case "list":
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		windows := []MaintenanceStatus{}
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodGet, "/api/v1/maintenance", nil, &windows)
+		if err != nil {
+			return err
+		}
+
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "NAME\tMODE\tACTIVE\tSOURCE\tWHEN\tSCOPE")
+
+		for _, window := range windows {
+			source := "config"
+			if window.Runtime {
+				source = "runtime"
+			}
+
+			when := window.Start + " - " + window.End
+			if window.Schedule != "" {
+				when = window.Schedule + " for " + window.Duration.String()
+			} else if window.End == "" {
+				when = window.Start + " for " + window.Duration.String()
+			}
+
+			if window.Timezone != "" {
+				when += " (" + window.Timezone + ")"
+			}
+
+			scope := strings.Join(append([]string{window.Domain}, window.Domains...), ",")
+			if len(window.Checks) > 0 {
+				scope += " checks=" + strings.Join(window.Checks, ",")
+			}
+
+			fmt.Fprintf(out, "%s\t%s\t%t\t%s\t%s\t%s\n", window.Name, window.Mode, window.Active, source, when, strings.Trim(scope, ","))
+		}
+
+		return out.Flush()
+	case "add":
+		var window MaintenanceWindow
+
+		flags.StringVar(&window.Name, "name", "", "window name")
+		flags.StringVar(&window.Start, "start", "", "start time, RFC 3339 or \"2006-01-02 15:04\"")
+		flags.StringVar(&window.End, "end", "", "end time, RFC 3339 or \"2006-01-02 15:04\"")
+		flags.StringVar(&window.Schedule, "schedule", "", "cron expression for recurring windows")
+		flags.DurationVar(&window.Duration, "duration", 0, "window length")
+		flags.StringVar(&window.Timezone, "timezone", "", "time zone of start, end and schedule")
+		flags.StringVar(&window.Mode, "mode", MaintenanceMute, "mute or pause")
+		flags.Var((*stringList)(&window.Domains), "domain", "domain key or address, repeatable")
+		flags.Var((*stringList)(&window.Checks), "check", "check key, repeatable")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodPost, "/api/v1/maintenance", window, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Maintenance window %s added\n", window.Name)
+
+		return nil
+	case "remove":
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: maintenance remove [flags] <name>")
+		}
+
+		name := flags.Arg(0)
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodDelete, "/api/v1/maintenance/"+url.PathEscape(name), nil, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Maintenance window %s removed\n", name)
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown maintenance command %q", args[0])
+}
+
+func escalationsCommand(args []string) error {
+	flags := flag.NewFlagSet("escalations", flag.ContinueOnError)
+	socket := flags.String("socket", "", "control socket path")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	escalations := []EscalationStatus{}
+
+	err = NewControlClient(controlSocket(*socket)).Do(http.MethodGet, "/api/v1/escalations", nil, &escalations)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "JOB\tPOLICY\tSTEP\tSTARTED\tNEXT\tACKNOWLEDGED")
+
+	for _, esc := range escalations {
+		next := "-"
+		if esc.NextAt != nil {
+			next = esc.NextAt.Local().Format(time.DateTime)
+		}
+
+		acknowledged := "no"
+		if esc.Acknowledged {
+			acknowledged = "by " + esc.AcknowledgedBy + " at " + esc.AcknowledgedAt.Local().Format(time.DateTime)
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%d/%d\t%s\t%s\t%s\n", esc.Job, esc.Policy, esc.Step, esc.Steps, esc.Started.Local().Format(time.DateTime), next, acknowledged)
+	}
+
+	return out.Flush()
+}
+
+func ackCommand(args []string) error {
+	flags := flag.NewFlagSet("ack", flag.ContinueOnError)
+	socket := flags.String("socket", "", "control socket path")
+
+	var ack AckRequest
+
+	flags.StringVar(&ack.By, "by", currentUser(), "who acknowledges")
+	flags.StringVar(&ack.Comment, "comment", "", "comment")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: ack [flags] <domain>/<check>")
+	}
+
+	domain, check, err := splitJob(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	path := "/api/v1/jobs/" + url.PathEscape(domain) + "/" + url.PathEscape(check) + "/ack"
+
+	err = NewControlClient(controlSocket(*socket)).Do(http.MethodPost, path, ack, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Acknowledged %s\n", flags.Arg(0))
+
+	return nil
+}
+
+func incidentsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: incidents list|show|ack|note [flags]")
+	}
+
+	flags := flag.NewFlagSet("incidents "+args[0], flag.ContinueOnError)
+	socket := flags.String("socket", "", "control socket path")
+
+	switch args[0] {
+	case "list":
+		all := flags.Bool("all", false, "include resolved incidents")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		path := "/api/v1/incidents"
+		if *all {
+			path += "?all"
+		}
+
+		incidents := []IncidentStatus{}
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodGet, path, nil, &incidents)
+		if err != nil {
+			return err
+		}
+
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "ID\tJOB\tSEVERITY\tSTARTED\tDURATION\tACKNOWLEDGED\tRESOLVED\tMESSAGE")
+
+		for _, incident := range incidents {
+			acknowledged := "-"
+			if incident.AcknowledgedAt != nil {
+				acknowledged = incident.AcknowledgedBy
+			}
+
+			resolved := "-"
+			if incident.ResolvedAt != nil {
+				resolved = incident.ResolvedAt.Local().Format(time.DateTime)
+			}
+
+			duration := time.Duration(incident.DurationSeconds * float64(time.Second)).Round(time.Second)
+
+			fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", incident.ID, incident.Job, incident.Severity, incident.StartedAt.Local().Format(time.DateTime), duration, acknowledged, resolved, incident.Message)
+		}
+
+		return out.Flush()
+	case "show":
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: incidents show [flags] <id>")
+		}
+
+		var incident IncidentStatus
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodGet, "/api/v1/incidents/"+url.PathEscape(flags.Arg(0)), nil, &incident)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Incident %d: %s\n", incident.ID, incident.Job)
+		fmt.Printf("Severity:     %s\n", incident.Severity)
+		fmt.Printf("Started:      %s\n", incident.StartedAt.Local().Format(time.DateTime))
+
+		if incident.AcknowledgedAt != nil {
+			fmt.Printf("Acknowledged: %s by %s\n", incident.AcknowledgedAt.Local().Format(time.DateTime), incident.AcknowledgedBy)
+		}
+
+		if incident.ResolvedAt != nil {
+			fmt.Printf("Resolved:     %s\n", incident.ResolvedAt.Local().Format(time.DateTime))
+		}
+
+		fmt.Printf("Duration:     %s\n", time.Duration(incident.DurationSeconds*float64(time.Second)).Round(time.Second))
+		fmt.Printf("Results:      %d\n", incident.Results)
+		fmt.Println()
+
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "AT\tKIND\tBY\tTEXT")
+
+		for _, note := range incident.Timeline {
+			by := note.By
+			if by == "" {
+				by = "-"
+			}
+
+			text := note.Text
+			if note.Severity != nil {
+				text = note.Severity.String() + ": " + text
+			}
+
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", note.At.Local().Format(time.DateTime), note.Kind, by, text)
+		}
+
+		return out.Flush()
+	case "ack":
+		var ack AckRequest
+
+		flags.StringVar(&ack.By, "by", currentUser(), "who acknowledges")
+		flags.StringVar(&ack.Comment, "comment", "", "comment")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: incidents ack [flags] <id>")
+		}
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodPost, "/api/v1/incidents/"+url.PathEscape(flags.Arg(0))+"/ack", ack, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Incident %s acknowledged\n", flags.Arg(0))
+
+		return nil
+	case "note":
+		var note NoteRequest
+
+		flags.StringVar(&note.By, "by", currentUser(), "author of the note")
+		flags.StringVar(&note.Text, "text", "", "note text")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		if flags.NArg() != 1 || note.Text == "" {
+			return fmt.Errorf("usage: incidents note -text <text> [flags] <id>")
+		}
+
+		err = NewControlClient(controlSocket(*socket)).Do(http.MethodPost, "/api/v1/incidents/"+url.PathEscape(flags.Arg(0))+"/notes", note, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Note added to incident %s\n", flags.Arg(0))
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown incidents command %q", args[0])
+}
+
+func historyCommand(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	socket := flags.String("socket", "", "control socket path")
+	since := flags.Duration("since", 0, "only show results of this long ago")
+	limit := flags.Int("limit", 20, "number of results to show")
+	failed := flags.Bool("failed", false, "only show failed results")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: history [flags] <domain>/<check>")
+	}
+
+	domain, check, err := splitJob(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(*limit))
+
+	if *since > 0 {
+		query.Set("from", time.Now().Add(-*since).Format(time.RFC3339))
+	}
+
+	if *failed {
+		query.Set("failed", "")
+	}
+
+	path := "/api/v1/jobs/" + url.PathEscape(domain) + "/" + url.PathEscape(check) + "/results?" + query.Encode()
+
+	records := []ResultRecord{}
+
+	err = NewControlClient(controlSocket(*socket)).Do(http.MethodGet, path, nil, &records)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "AT\tDURATION\tSUCCESS\tSEVERITY\tMESSAGE\tMETRICS")
+
+	for _, record := range records {
+		metrics := []string{}
+		for name, value := range record.Metrics {
+			metrics = append(metrics, fmt.Sprintf("%s=%g", name, value))
+		}
+
+		slices.Sort(metrics)
+
+		fmt.Fprintf(out, "%s\t%s\t%t\t%s\t%s\t%s\n", record.At.Local().Format(time.DateTime), record.Duration.Round(time.Millisecond), record.Success, record.Severity, record.Message, strings.Join(metrics, " "))
+	}
+
+	return out.Flush()
+}
+
+func slaCommand(args []string) error {
+	flags := flag.NewFlagSet("sla", flag.ContinueOnError)
+	socket := flags.String("socket", "", "control socket path")
+	domain := flags.String("domain", "", "only show this domain")
+	jobs := flags.Bool("jobs", false, "show every job below its domain")
+
+	var windows []string
+
+	flags.Var((*stringList)(&windows), "window", "window like 24h, 7d or month, repeatable")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query["window"] = windows
+
+	if *domain != "" {
+		query.Set("domain", *domain)
+	}
+
+	domains := []DomainAvailability{}
+
+	err = NewControlClient(controlSocket(*socket)).Do(http.MethodGet, "/api/v1/sla?"+query.Encode(), nil, &domains)
+	if err != nil {
+		return err
+	}
+
+	if len(domains) == 0 {
+		return nil
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprint(out, "NAME")
+	for _, window := range domains[0].Windows {
+		fmt.Fprintf(out, "\t%s", strings.ToUpper(window.Window))
+	}
+
+	fmt.Fprintln(out)
+
+	row := func(name string, windows []Availability) {
+		fmt.Fprint(out, name)
+
+		for _, window := range windows {
+			if window.Percent == nil {
+				fmt.Fprint(out, "\t-")
+			} else {
+				fmt.Fprintf(out, "\t%.3f%%", *window.Percent)
+			}
+		}
+
+		fmt.Fprintln(out)
+	}
+
+	for _, domain := range domains {
+		row(domain.Domain, domain.Windows)
+
+		if *jobs {
+			for _, job := range domain.Jobs {
+				row("  "+job.Check, job.Windows)
+			}
+		}
+	}
+
+	return out.Flush()
+}
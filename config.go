@@ -1,21 +1,180 @@
 package main
 
 import (
+	"os"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type CheckConfig struct {
-	Key      string            `yaml:"key"`
-	Interval time.Duration     `yaml:"interval"`
-	Args     map[string]string `yaml:",inline"`
+	Key      string        `yaml:"key" json:"key"`
+	Interval time.Duration `yaml:"interval" json:"interval,omitempty"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout,omitempty"`
+	Jitter   time.Duration `yaml:"jitter" json:"jitter,omitempty"`
+	Schedule string        `yaml:"schedule" json:"schedule,omitempty"`
+
+	DependsOn []string `yaml:"depends_on" json:"depends_on,omitempty"`
+	Tags      []string `yaml:"tags" json:"tags,omitempty"`
+
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold,omitempty"`
+	SuccessThreshold int `yaml:"success_threshold" json:"success_threshold,omitempty"`
+
+	FailureInterval    time.Duration `yaml:"failure_interval" json:"failure_interval,omitempty"`
+	FailureBackoff     float64       `yaml:"failure_backoff" json:"failure_backoff,omitempty"`
+	FailureIntervalMax time.Duration `yaml:"failure_interval_max" json:"failure_interval_max,omitempty"`
+
+	FlapWindow        int     `yaml:"flap_window" json:"flap_window,omitempty"`
+	FlapThresholdLow  float64 `yaml:"flap_threshold_low" json:"flap_threshold_low,omitempty"`
+	FlapThresholdHigh float64 `yaml:"flap_threshold_high" json:"flap_threshold_high,omitempty"`
+
+	Args map[string]string `yaml:",inline" json:"args,omitempty"`
 }
 
 type Domain struct {
-	Domain   string        `yaml:"domain"`
-	Interval time.Duration `yaml:"interval"`
-	Checks   []CheckConfig `yaml:"checks"`
+	Key      string        `yaml:"key" json:"key,omitempty"`
+	Domain   string        `yaml:"domain" json:"domain"`
+	Interval time.Duration `yaml:"interval" json:"interval,omitempty"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout,omitempty"`
+	Jitter   time.Duration `yaml:"jitter" json:"jitter,omitempty"`
+	Checks   []CheckConfig `yaml:"checks" json:"checks"`
+	Tags     []string      `yaml:"tags" json:"tags,omitempty"`
+
+	// Escalation names the escalation policy for the jobs of the domain.
+	Escalation string `yaml:"escalation" json:"escalation,omitempty"`
+
+	Maintenance []MaintenanceWindow `yaml:"maintenance" json:"maintenance,omitempty"`
+}
+
+// MaintenanceWindow is either a one-off window between Start and End, or a
+// recurring one that opens on every Schedule match and lasts Duration. Start
+// and End are RFC 3339 or "2006-01-02 15:04" in Timezone.
+type MaintenanceWindow struct {
+	Name     string        `yaml:"name" json:"name"`
+	Start    string        `yaml:"start" json:"start,omitempty"`
+	End      string        `yaml:"end" json:"end,omitempty"`
+	Schedule string        `yaml:"schedule" json:"schedule,omitempty"`
+	Duration time.Duration `yaml:"duration" json:"duration,omitempty"`
+	Timezone string        `yaml:"timezone" json:"timezone,omitempty"`
+
+	// Mode is "mute" to keep running checks without alerting, or "pause" to
+	// skip them.
+	Mode string `yaml:"mode" json:"mode,omitempty"`
+
+	// Domains and Checks limit the window to matching jobs. Domains are
+	// referenced by key or address and are ignored for domain windows.
+	Domains []string `yaml:"domains" json:"domains,omitempty"`
+	Checks  []string `yaml:"checks" json:"checks,omitempty"`
+}
+
+// NotifierConfig is a named notifier instance. Kind selects the notifier a
+// plugin registered, the remaining keys are passed to it as arguments.
+type NotifierConfig struct {
+	Name string            `yaml:"name"`
+	Kind string            `yaml:"kind"`
+	Args map[string]string `yaml:",inline"`
+}
+
+// EscalationPolicy notifies further tiers of notifiers while a job stays
+// DOWN without being acknowledged. The first step fires Delay after the job
+// went down, every later step Delay after the previous one.
+type EscalationPolicy struct {
+	Name  string           `yaml:"name"`
+	Steps []EscalationStep `yaml:"steps"`
+}
+
+type EscalationStep struct {
+	Delay     time.Duration `yaml:"delay"`
+	Notifiers []string      `yaml:"notifiers"`
+}
+
+// ResultsConfig configures the result store. The log backend starts a new
+// segment every SegmentSize bytes or SegmentDuration, and deletes segments
+// once all their results are older than Retention. The none backend keeps
+// nothing. Resolved incidents are kept for Retention with either backend.
+type ResultsConfig struct {
+	Backend         string        `yaml:"backend"`
+	Retention       time.Duration `yaml:"retention"`
+	SegmentSize     int64         `yaml:"segment_size"`
+	SegmentDuration time.Duration `yaml:"segment_duration"`
+}
+
+// SLAConfig configures availability reports. Windows are durations like
+// "24h" or "7d", or "month" for the calendar month so far in Timezone.
+// Policy says whether failed results of a severity count as up, down or are
+// ignored. Results during maintenance are ignored unless IncludeMaintenance
+// is set.
+type SLAConfig struct {
+	Windows            []string          `yaml:"windows"`
+	Timezone           string            `yaml:"timezone"`
+	Policy             map[string]string `yaml:"policy"`
+	IncludeMaintenance bool              `yaml:"include_maintenance"`
+}
+
+// Route selects the notifiers an event is sent to. Routes form a tree: an
+// event descends into the first matching child route, or into every matching
+// child up to the first one without Continue, and stays at the parent if no
+// child matches. Empty matchers match everything. Notifiers, the escalation
+// policy and the group settings are inherited from the parent when not set.
+//
+// Events of a route are batched into groups by the GroupBy labels: domain,
+// check, job, severity and state. A new group waits GroupWait before it is
+// sent, later events of the group are sent every GroupInterval.
+type Route struct {
+	Notifiers []string `yaml:"notifiers"`
+
+	Domains     []string `yaml:"domains"`
+	Checks      []string `yaml:"checks"`
+	Tags        []string `yaml:"tags"`
+	Severities  []string `yaml:"severities"`
+	MinSeverity string   `yaml:"min_severity"`
+
+	Continue   bool   `yaml:"continue"`
+	Escalation string `yaml:"escalation"`
+
+	GroupBy       []string       `yaml:"group_by"`
+	GroupWait     *time.Duration `yaml:"group_wait"`
+	GroupInterval *time.Duration `yaml:"group_interval"`
+
+	Routes []Route `yaml:"routes"`
 }
 
 type Config struct {
-	Domains []Domain `yaml:"domains"`
+	Concurrency   int           `yaml:"concurrency"`
+	Splay         bool          `yaml:"splay"`
+	ShutdownGrace time.Duration `yaml:"shutdown_grace"`
+	WatchConfig   bool          `yaml:"watch_config"`
+
+	QuarantineRetry time.Duration `yaml:"quarantine_retry"`
+	ControlSocket   string        `yaml:"control_socket"`
+	DataDir         string        `yaml:"data_dir"`
+	MetricsListen   string        `yaml:"metrics_listen"`
+	StatusListen    string        `yaml:"status_listen"`
+
+	Results ResultsConfig `yaml:"results"`
+	SLA     SLAConfig     `yaml:"sla"`
+
+	Notifiers []NotifierConfig `yaml:"notifiers"`
+	Route     *Route           `yaml:"route"`
+
+	EscalationPolicies []EscalationPolicy `yaml:"escalation_policies"`
+
+	Maintenance []MaintenanceWindow `yaml:"maintenance"`
+	Domains     []Domain            `yaml:"domains"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
 }
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultControlSocket = "uptime-gopher.sock"
+
+	// incidentResultLead is how long before an incident opened its results
+	// are looked up. Results carry the time their check started, which is
+	// before the incident was opened by up to the check timeout.
+	incidentResultLead = time.Hour
+)
+
+// ControlServer serves the control API on a unix socket. The CLI subcommands
+// talk to a running instance through it.
+type ControlServer struct {
+	log *slog.Logger
+
+	app        *App
+	scheduler  *Scheduler
+	dispatcher *Dispatcher
+	incidents  *IncidentStore
+	results    ResultStore
+
+	path     string
+	listener net.Listener
+	server   *http.Server
+}
+
+func NewControlServer(log *slog.Logger, app *App, scheduler *Scheduler, dispatcher *Dispatcher, incidents *IncidentStore, results ResultStore, path string) *ControlServer {
+	log = log.With("service", "Control")
+
+	if path == "" {
+		path = defaultControlSocket
+	}
+
+	control := &ControlServer{
+		log: log,
+
+		app:        app,
+		scheduler:  scheduler,
+		dispatcher: dispatcher,
+		incidents:  incidents,
+		results:    results,
+
+		path: path,
+	}
+
+	mux := http.NewServeMux()
+	control.handleReadOnly(mux)
+	mux.HandleFunc("POST /api/v1/maintenance", control.addMaintenance)
+	mux.HandleFunc("DELETE /api/v1/maintenance/{name}", control.removeMaintenance)
+	mux.HandleFunc("POST /api/v1/jobs/{domain}/{check}/ack", control.acknowledge)
+	mux.HandleFunc("POST /api/v1/incidents/{id}/ack", control.acknowledgeIncident)
+	mux.HandleFunc("POST /api/v1/incidents/{id}/notes", control.addIncidentNote)
+
+	control.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return control
+}
+
+// handleReadOnly registers the endpoints that don't change anything.
+func (c *ControlServer) handleReadOnly(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/domains", c.listDomains)
+	mux.HandleFunc("GET /api/v1/jobs", c.listJobs)
+	mux.HandleFunc("GET /api/v1/jobs/{domain}/{check}", c.getJob)
+	mux.HandleFunc("GET /api/v1/jobs/{domain}/{check}/results", c.listResults)
+	mux.HandleFunc("GET /api/v1/plugins", c.listPlugins)
+	mux.HandleFunc("GET /api/v1/maintenance", c.listMaintenance)
+	mux.HandleFunc("GET /api/v1/escalations", c.listEscalations)
+	mux.HandleFunc("GET /api/v1/sla", c.availability)
+	mux.HandleFunc("GET /api/v1/incidents", c.listIncidents)
+	mux.HandleFunc("GET /api/v1/incidents/{id}", c.getIncident)
+	mux.HandleFunc("GET /api/v1/incidents/{id}/results", c.listIncidentResults)
+}
+
+// ReadOnlyHandler returns a handler with only the endpoints that don't
+// change anything, for the status server.
+func (c *ControlServer) ReadOnlyHandler() http.Handler {
+	mux := http.NewServeMux()
+	c.handleReadOnly(mux)
+
+	return mux
+}
+
+// Listen opens the socket. A socket file left behind by a crashed instance is
+// removed, one that still accepts connections is an error.
+func (c *ControlServer) Listen() error {
+	if _, err := os.Stat(c.path); err == nil {
+		conn, err := net.Dial("unix", c.path)
+		if err == nil {
+			conn.Close()
+
+			return fmt.Errorf("control socket %s is in use", c.path)
+		}
+
+		err = os.Remove(c.path)
+		if err != nil {
+			return fmt.Errorf("remove stale control socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", c.path)
+	if err != nil {
+		return err
+	}
+
+	c.listener = listener
+
+	c.log.Info("Control socket listening", "path", c.path)
+
+	return nil
+}
+
+func (c *ControlServer) Serve() {
+	err := c.server.Serve(c.listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		c.log.Error("Control server failed", "error", err)
+	}
+}
+
+// Close stops the server and removes the socket.
+func (c *ControlServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := c.server.Shutdown(ctx)
+	if err != nil {
+		c.log.Warn("Control server shutdown failed", "error", err)
+	}
+
+	os.Remove(c.path)
+}
+
+func (c *ControlServer) listMaintenance(w http.ResponseWriter, r *http.Request) {
+	windows, ok := c.scheduler.MaintenanceWindows(time.Now())
+	if !ok {
+		writeError(w, errNotRunning)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, windows)
+}
+
+func (c *ControlServer) addMaintenance(w http.ResponseWriter, r *http.Request) {
+	var window MaintenanceWindow
+
+	err := json.NewDecoder(r.Body).Decode(&window)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+
+		return
+	}
+
+	err = c.scheduler.AddMaintenance(window)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, window)
+}
+
+func (c *ControlServer) removeMaintenance(w http.ResponseWriter, r *http.Request) {
+	err := c.scheduler.RemoveMaintenance(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *ControlServer) listEscalations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.dispatcher.Escalations())
+}
+
+// AckRequest is the body of an acknowledgement.
+type AckRequest struct {
+	By      string `json:"by"`
+	Comment string `json:"comment,omitempty"`
+}
+
+func (c *ControlServer) acknowledge(w http.ResponseWriter, r *http.Request) {
+	var ack AckRequest
+
+	err := json.NewDecoder(r.Body).Decode(&ack)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+
+		return
+	}
+
+	job := r.PathValue("domain") + "/" + r.PathValue("check")
+
+	incident := c.incidents.AcknowledgeJob(job, ack.By, ack.Comment)
+
+	err = c.dispatcher.Acknowledge(job, ack.By, ack.Comment)
+	if err != nil && !(incident && errors.Is(err, errNotFound)) {
+		writeError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listResults returns the stored results of a job, oldest first. from and
+// to limit the time range, failed keeps only failed results, and limit keeps
+// the last results, 100 by default.
+func (c *ControlServer) listResults(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	job := r.PathValue("domain") + "/" + r.PathValue("check")
+
+	var from, to time.Time
+	var err error
+
+	if value := query.Get("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, fmt.Errorf("invalid from: %w", err))
+
+			return
+		}
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, fmt.Errorf("invalid to: %w", err))
+
+			return
+		}
+	}
+
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeError(w, fmt.Errorf("invalid limit %q", value))
+
+			return
+		}
+	}
+
+	failed := query.Has("failed")
+
+	var records []ResultRecord
+	if from.IsZero() && to.IsZero() && !failed {
+		records, err = c.results.Tail(job, limit)
+	} else {
+		records, err = c.results.Query(job, from, to)
+	}
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+
+		return
+	}
+
+	if failed {
+		records = slices.DeleteFunc(records, func(record ResultRecord) bool {
+			return record.Success
+		})
+	}
+
+	writeJSON(w, http.StatusOK, records[max(0, len(records)-limit):])
+}
+
+// availability returns the availability of every domain and its jobs. domain
+// limits it to one domain, window is repeatable and selects the windows.
+func (c *ControlServer) availability(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	domains, err := c.scheduler.Availability(query.Get("domain"), query["window"], time.Now())
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domains)
+}
+
+func (c *ControlServer) listIncidents(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	statuses := []IncidentStatus{}
+
+	for _, incident := range c.incidents.List(r.URL.Query().Has("all")) {
+		statuses = append(statuses, incident.Status(now))
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (c *ControlServer) getIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := incidentID(r)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	incident, err := c.incidents.Get(id)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, incident.Status(time.Now()))
+}
+
+// listIncidentResults returns the results linked to an incident, oldest
+// first.
+func (c *ControlServer) listIncidentResults(w http.ResponseWriter, r *http.Request) {
+	id, err := incidentID(r)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	incident, err := c.incidents.Get(id)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	var to time.Time
+	if incident.ResolvedAt != nil {
+		to = *incident.ResolvedAt
+	}
+
+	records, err := c.results.Query(incident.Job, incident.StartedAt.Add(-incidentResultLead), to)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+
+		return
+	}
+
+	records = slices.DeleteFunc(records, func(record ResultRecord) bool {
+		return record.Incident != id
+	})
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// acknowledgeIncident acknowledges an incident and the escalations of its
+// job.
+func (c *ControlServer) acknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := incidentID(r)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	var ack AckRequest
+
+	err = json.NewDecoder(r.Body).Decode(&ack)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+
+		return
+	}
+
+	job, err := c.incidents.Acknowledge(id, ack.By, ack.Comment)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	err = c.dispatcher.Acknowledge(job, ack.By, ack.Comment)
+	if err != nil && !errors.Is(err, errNotFound) {
+		writeError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// NoteRequest is the body of a note added to an incident.
+type NoteRequest struct {
+	By   string `json:"by"`
+	Text string `json:"text"`
+}
+
+func (c *ControlServer) addIncidentNote(w http.ResponseWriter, r *http.Request) {
+	id, err := incidentID(r)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	var note NoteRequest
+
+	err = json.NewDecoder(r.Body).Decode(&note)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+
+		return
+	}
+
+	if note.Text == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "note needs a text"})
+
+		return
+	}
+
+	err = c.incidents.AddNote(id, note.By, note.Text)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func incidentID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid incident id %q", r.PathValue("id"))
+	}
+
+	return id, nil
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError maps err to a status code. Errors that aren't known are caused
+// by the request.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+
+	switch {
+	case errors.Is(err, errNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errNotRunning):
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
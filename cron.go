@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression. Each field is a bitset of the
+// values it matches.
+type CronSchedule struct {
+	second uint64
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// domAny and dowAny record a `*` or `?` in the day fields. When both day
+	// fields are restricted a day matches if either of them does.
+	domAny bool
+	dowAny bool
+
+	loc *time.Location
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "second", min: 0, max: 59}
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard 5-field cron expression or a 6-field one with
+// leading seconds. The expression may be prefixed with `CRON_TZ=<zone>` or
+// `TZ=<zone>`; otherwise it is evaluated in local time.
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	schedule := &CronSchedule{
+		loc: time.Local,
+	}
+
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		zone, rest, _ := strings.Cut(expr, " ")
+		_, name, _ := strings.Cut(zone, "=")
+
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", name, err)
+		}
+
+		schedule.loc = loc
+		expr = strings.TrimSpace(rest)
+	}
+
+	if descriptor, ok := cronDescriptors[expr]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("expected 5 or 6 fields, got %d", len(fields))
+	}
+
+	var err error
+
+	if schedule.second, err = cronSecond.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if schedule.minute, err = cronMinute.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if schedule.hour, err = cronHour.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if schedule.dom, err = cronDom.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if schedule.month, err = cronMonth.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	if schedule.dow, err = cronDow.parse(fields[5]); err != nil {
+		return nil, err
+	}
+
+	// 7 is an alias for Sunday.
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	schedule.domAny = strings.HasPrefix(fields[3], "*") || fields[3] == "?"
+	schedule.dowAny = strings.HasPrefix(fields[5], "*") || fields[5] == "?"
+
+	return schedule, nil
+}
+
+func (f cronField) parse(expr string) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			num, err := strconv.Atoi(stepExpr)
+			if err != nil || num <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepExpr, f.name)
+			}
+
+			step = num
+		}
+
+		start, end := f.min, f.max
+
+		if rangeExpr != "*" && rangeExpr != "?" {
+			lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+
+			low, err := f.value(lowExpr)
+			if err != nil {
+				return 0, err
+			}
+
+			start, end = low, low
+			if isRange {
+				high, err := f.value(highExpr)
+				if err != nil {
+					return 0, err
+				}
+
+				end = high
+			} else if hasStep {
+				end = f.max
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q in %s field", rangeExpr, f.name)
+		}
+
+		for i := start; i <= end; i += step {
+			set |= 1 << i
+		}
+	}
+
+	return set, nil
+}
+
+func (f cronField) value(expr string) (int, error) {
+	if num, ok := f.names[strings.ToLower(expr)]; ok {
+		return num, nil
+	}
+
+	num, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", expr, f.name)
+	}
+
+	if num < f.min || num > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d", f.name, f.min, f.max)
+	}
+
+	return num, nil
+}
+
+// Next returns the first time after t that matches the schedule, or the zero
+// time if nothing matches within five years. Times skipped by a daylight
+// saving change never match, and times repeated by one match twice.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	t = t.In(c.loc).Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = c.advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc))
+
+			continue
+		}
+
+		if !c.matchDay(t) {
+			t = c.advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc))
+
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = c.advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc))
+
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+
+			continue
+		}
+
+		if c.second&(1<<uint(t.Second())) == 0 {
+			t = t.Add(time.Second)
+
+			continue
+		}
+
+		return t.In(loc)
+	}
+
+	return time.Time{}
+}
+
+// advance returns next, the start of a later month, day or hour than t. When
+// that start falls into a daylight saving gap time.Date may resolve it to
+// before the gap, and so to t again, so it is moved past the gap.
+func (c *CronSchedule) advance(t, next time.Time) time.Time {
+	for !next.After(t) {
+		next = next.Add(time.Hour)
+	}
+
+	return next
+}
+
+func (c *CronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
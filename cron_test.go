@@ -0,0 +1,174 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"0 9 * * *", true},
+		{"*/15 9-17 * * mon-fri", true},
+		{"0 0 1 jan,JUL *", true},
+		{"*/30 * * * * *", true},
+		{"0 0 * * 7", true},
+		{"0 0 ? * sun", true},
+		{"@daily", true},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", true},
+		{"TZ=UTC @hourly", true},
+
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"* * * foo *", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"@reboot", false},
+		{"CRON_TZ=Nowhere/City 0 9 * * *", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := ParseCron(test.expr)
+			if test.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !test.ok && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want []string
+	}{
+		{
+			name: "daily",
+			expr: "TZ=UTC 0 9 * * *",
+			from: "2024-01-01T08:00:00Z",
+			want: []string{"2024-01-01T09:00:00Z", "2024-01-02T09:00:00Z"},
+		},
+		{
+			name: "business hours skip the weekend",
+			expr: "TZ=UTC */15 9-17 * * mon-fri",
+			from: "2024-01-05T17:40:00Z",
+			want: []string{"2024-01-05T17:45:00Z", "2024-01-08T09:00:00Z"},
+		},
+		{
+			name: "named months",
+			expr: "TZ=UTC 0 0 1 jan,jul *",
+			from: "2024-02-01T00:00:00Z",
+			want: []string{"2024-07-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		},
+		{
+			name: "range with step",
+			expr: "TZ=UTC 10-30/10 * * * *",
+			from: "2024-01-01T00:25:00Z",
+			want: []string{"2024-01-01T00:30:00Z", "2024-01-01T01:10:00Z"},
+		},
+		{
+			name: "seconds",
+			expr: "TZ=UTC */30 * * * * *",
+			from: "2024-01-01T10:00:10Z",
+			want: []string{"2024-01-01T10:00:30Z", "2024-01-01T10:01:00Z"},
+		},
+		{
+			name: "day of month or day of week",
+			expr: "TZ=UTC 0 0 13 * fri",
+			from: "2024-01-06T00:00:00Z",
+			want: []string{"2024-01-12T00:00:00Z", "2024-01-13T00:00:00Z", "2024-01-19T00:00:00Z"},
+		},
+		{
+			name: "day of month with any day of week",
+			expr: "TZ=UTC 0 0 13 * *",
+			from: "2024-01-06T00:00:00Z",
+			want: []string{"2024-01-13T00:00:00Z", "2024-02-13T00:00:00Z"},
+		},
+		{
+			name: "sunday as 7",
+			expr: "TZ=UTC 0 0 * * 7",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-07T00:00:00Z"},
+		},
+		{
+			name: "leap day",
+			expr: "TZ=UTC 0 0 29 feb *",
+			from: "2024-03-01T00:00:00Z",
+			want: []string{"2028-02-29T00:00:00Z"},
+		},
+		{
+			name: "time zone",
+			expr: "CRON_TZ=Asia/Tokyo 0 9 * * *",
+			from: "2023-12-31T23:00:00Z",
+			want: []string{"2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z"},
+		},
+		{
+			name: "spring forward skips the missing time",
+			expr: "CRON_TZ=America/New_York 30 2 * * *",
+			from: "2024-03-09T12:00:00-05:00",
+			want: []string{"2024-03-11T02:30:00-04:00"},
+		},
+		{
+			name: "spring forward hourly",
+			expr: "CRON_TZ=America/New_York 0 * * * *",
+			from: "2024-03-10T00:30:00-05:00",
+			want: []string{"2024-03-10T01:00:00-05:00", "2024-03-10T03:00:00-04:00"},
+		},
+		{
+			name: "fall back repeats the time",
+			expr: "CRON_TZ=America/New_York 30 1 * * *",
+			from: "2024-11-03T00:00:00-04:00",
+			want: []string{"2024-11-03T01:30:00-04:00", "2024-11-03T01:30:00-05:00", "2024-11-04T01:30:00-05:00"},
+		},
+		{
+			name: "never fires",
+			expr: "TZ=UTC 0 0 30 feb *",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"0001-01-01T00:00:00Z"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expr)
+			if err != nil {
+				t.Fatalf("parse %q: %v", test.expr, err)
+			}
+
+			at := parseTestTime(t, test.from)
+
+			for _, want := range test.want {
+				at = schedule.Next(at)
+
+				if !at.Equal(parseTestTime(t, want)) {
+					t.Fatalf("got %s, want %s", at.Format(time.RFC3339), want)
+				}
+			}
+		})
+	}
+}
+
+func parseTestTime(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return at
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func jobKey(domain Domain, checkConfig CheckConfig) string {
+	return domain.Domain + "/" + checkConfig.Key
+}
+
+// resolveDependencies maps every job key to the keys of the jobs it depends
+// on. A dependency is either a check key on the same domain, or
+// `<domain>/<check>` where domain is the key or the address of a domain.
+func resolveDependencies(config Config) (map[string][]string, error) {
+	domains := map[string]Domain{}
+	for _, domain := range config.Domains {
+		domains[domain.Domain] = domain
+	}
+
+	for _, domain := range config.Domains {
+		if domain.Key != "" {
+			domains[domain.Key] = domain
+		}
+	}
+
+	graph := map[string][]string{}
+	for _, domain := range config.Domains {
+		for _, checkConfig := range domain.Checks {
+			key := jobKey(domain, checkConfig)
+			graph[key] = []string{}
+
+			for _, ref := range checkConfig.DependsOn {
+				target := domain
+				targetCheck := ref
+
+				if i := strings.LastIndex(ref, "/"); i >= 0 {
+					found, ok := domains[ref[:i]]
+					if !ok {
+						return nil, fmt.Errorf("domain %s: check %s: depends on unknown domain %s", domain.Domain, checkConfig.Key, ref[:i])
+					}
+
+					target = found
+					targetCheck = ref[i+1:]
+				}
+
+				if !hasCheck(target, targetCheck) {
+					return nil, fmt.Errorf("domain %s: check %s: depends on unknown check %s", domain.Domain, checkConfig.Key, ref)
+				}
+
+				graph[key] = append(graph[key], target.Domain+"/"+targetCheck)
+			}
+		}
+	}
+
+	err := findDependencyCycle(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph, nil
+}
+
+func hasCheck(domain Domain, key string) bool {
+	for _, checkConfig := range domain.Checks {
+		if checkConfig.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findDependencyCycle walks the graph depth first and reports the first
+// cycle it finds.
+func findDependencyCycle(graph map[string][]string) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	marks := map[string]int{}
+	path := []string{}
+
+	var visit func(key string) error
+	visit = func(key string) error {
+		switch marks[key] {
+		case visiting:
+			start := 0
+			for i, node := range path {
+				if node == key {
+					start = i
+				}
+			}
+
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path[start:], key), " -> "))
+		case visited:
+			return nil
+		}
+
+		marks[key] = visiting
+		path = append(path, key)
+
+		for _, parent := range graph[key] {
+			err := visit(parent)
+			if err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		marks[key] = visited
+
+		return nil
+	}
+
+	keys := make([]string, 0, len(graph))
+	for key := range graph {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if marks[key] == unvisited {
+			err := visit(key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
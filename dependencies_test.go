@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		domains []Domain
+		want    map[string]string
+		err     string
+	}{
+		{
+			name: "same domain and other domain by key",
+			domains: []Domain{
+				{Domain: "example.com", Checks: []CheckConfig{{Key: "dns"}, {Key: "http", DependsOn: []string{"dns", "db/tcp"}}}},
+				{Domain: "db.example.com", Key: "db", Checks: []CheckConfig{{Key: "tcp"}}},
+			},
+			want: map[string]string{
+				"example.com/dns":    "",
+				"example.com/http":   "example.com/dns,db.example.com/tcp",
+				"db.example.com/tcp": "",
+			},
+		},
+		{
+			name: "self loop",
+			domains: []Domain{
+				{Domain: "example.com", Checks: []CheckConfig{{Key: "http", DependsOn: []string{"http"}}}},
+			},
+			err: "dependency cycle: example.com/http -> example.com/http",
+		},
+		{
+			name: "indirect cycle",
+			domains: []Domain{
+				{Domain: "example.com", Checks: []CheckConfig{
+					{Key: "dns", DependsOn: []string{"http"}},
+					{Key: "http", DependsOn: []string{"ssl"}},
+					{Key: "ssl", DependsOn: []string{"dns"}},
+				}},
+			},
+			err: "dependency cycle: example.com/dns -> example.com/http -> example.com/ssl -> example.com/dns",
+		},
+		{
+			name: "unknown check",
+			domains: []Domain{
+				{Domain: "example.com", Checks: []CheckConfig{{Key: "http", DependsOn: []string{"dns"}}}},
+			},
+			err: "depends on unknown check dns",
+		},
+		{
+			name: "unknown domain",
+			domains: []Domain{
+				{Domain: "example.com", Checks: []CheckConfig{{Key: "http", DependsOn: []string{"db/tcp"}}}},
+			},
+			err: "depends on unknown domain db",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph, err := resolveDependencies(Config{Domains: test.domains})
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(graph) != len(test.want) {
+				t.Fatalf("got %d jobs, want %d", len(graph), len(test.want))
+			}
+
+			for key, want := range test.want {
+				if got := strings.Join(graph[key], ","); got != want {
+					t.Fatalf("%s depends on %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerSuppress(t *testing.T) {
+	config := Config{
+		Domains: []Domain{
+			{Domain: "example.com", Checks: []CheckConfig{
+				{Key: "dns"},
+				{Key: "http", DependsOn: []string{"dns"}},
+				{Key: "ssl", DependsOn: []string{"http"}},
+			}},
+		},
+	}
+
+	dependencies, err := resolveDependencies(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := map[string]*Job{}
+	list := []*Job{}
+
+	for _, checkConfig := range config.Domains[0].Checks {
+		job, err := NewJob(config.Domains[0], Check{Name: checkConfig.Key}, checkConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		job.dependsOn = dependencies[job.Key()]
+
+		jobs[checkConfig.Key] = job
+		list = append(list, job)
+	}
+
+	s := testScheduler(t, Config{}, &memoryStore{}, list...)
+
+	tests := []struct {
+		parent State
+		want   string
+	}{
+		{StateUp, ""},
+		{StateDegraded, ""},
+		{StateDown, "http,ssl"},
+		{StateUp, ""},
+	}
+
+	for _, test := range tests {
+		jobs["dns"].state = test.parent
+
+		suppressed := []string{}
+		for _, job := range list {
+			if s.suppress(job) {
+				suppressed = append(suppressed, job.checkConfig.Key)
+			}
+		}
+
+		if got := strings.Join(suppressed, ","); got != test.want {
+			t.Fatalf("parent %s: got %q suppressed, want %q", test.parent, got, test.want)
+		}
+	}
+}
@@ -94,13 +94,18 @@ func NewDynamicPlugin(fs fs.FS) (*DynamicPlugin, error) {
 	}, nil
 }
 
-func LoadDynamicPluginsFromDir(dir string) ([]*DynamicPlugin, error) {
+// LoadDynamicPluginsFromDir loads every folder in dir as a plugin. Folders
+// that fail to load are skipped, their errors are returned in the second
+// result. The error is only set if dir can't be read.
+func LoadDynamicPluginsFromDir(dir string) ([]*DynamicPlugin, []error, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	plugins := []*DynamicPlugin{}
+	loadErrs := []error{}
+
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -110,11 +115,13 @@ func LoadDynamicPluginsFromDir(dir string) ([]*DynamicPlugin, error) {
 
 		plugin, err := NewDynamicPlugin(os.DirFS(folder))
 		if err != nil {
-			return nil, err
+			loadErrs = append(loadErrs, fmt.Errorf("plugin %s: %w", folder, err))
+
+			continue
 		}
 
 		plugins = append(plugins, plugin)
 	}
 
-	return plugins, nil
+	return plugins, loadErrs, nil
 }
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStub is an SMTP server for one connection that records what the
+// client sent.
+type smtpStub struct {
+	tls      *tls.Config
+	implicit bool
+
+	secure   bool
+	auth     string
+	username string
+	password string
+	from     string
+	rcpts    []string
+	data     string
+}
+
+func (s *smtpStub) serve(conn net.Conn) error {
+	defer conn.Close()
+
+	if s.implicit {
+		conn = tls.Server(conn, s.tls)
+		s.secure = true
+	}
+
+	text := textproto.NewConn(conn)
+
+	err := text.PrintfLine("220 smtp.example.com ESMTP stub")
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-smtp.example.com")
+
+			if !s.secure {
+				text.PrintfLine("250-STARTTLS")
+			}
+
+			text.PrintfLine("250 AUTH PLAIN LOGIN")
+		case "STARTTLS":
+			text.PrintfLine("220 Ready to start TLS")
+
+			conn = tls.Server(conn, s.tls)
+			text = textproto.NewConn(conn)
+			s.secure = true
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+
+			err = s.authenticate(text, mechanism, initial)
+			if err != nil {
+				return err
+			}
+
+			text.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			s.from = arg
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			s.rcpts = append(s.rcpts, arg)
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return err
+			}
+
+			s.data = string(data)
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			err = text.PrintfLine("221 Bye")
+			if err != nil {
+				return err
+			}
+
+			// Wait for the client to close, so a TLS client can still send
+			// its close_notify.
+			io.Copy(io.Discard, conn)
+
+			return nil
+		default:
+			text.PrintfLine("502 Unknown command")
+		}
+	}
+}
+
+func (s *smtpStub) authenticate(text *textproto.Conn, mechanism string, initial string) error {
+	s.auth = mechanism
+
+	switch mechanism {
+	case "PLAIN":
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) == 3 {
+			s.username, s.password = parts[1], parts[2]
+		}
+	case "LOGIN":
+		for _, field := range []*string{&s.username, &s.password} {
+			prompt := "Username:"
+			if field == &s.password {
+				prompt = "Password:"
+			}
+
+			text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+
+			line, err := text.ReadLine()
+			if err != nil {
+				return err
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(line)
+			if err != nil {
+				return err
+			}
+
+			*field = string(decoded)
+		}
+	}
+
+	return nil
+}
+
+func stubCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtp.example.com"},
+		DNSNames:     []string{"smtp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// stdApp loads the std plugin, which runs in yaegi.
+func stdApp(t *testing.T) *App {
+	t.Helper()
+
+	plugin, err := NewDynamicPlugin(os.DirFS("plugins/uptime-gopher-std"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err = app.AddPlugin(plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return app
+}
+
+// stdNotifier builds a notifier of the std plugin, which runs in yaegi.
+func stdNotifier(t *testing.T, app *App, kind string, args map[string]string) Notifier {
+	t.Helper()
+
+	notifier, err := app.notifiers[kind]("test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return notifier
+}
+
+// setDial replaces the Dial hook of an interpreted notifier.
+func setDial(t *testing.T, notifier Notifier, dial func(context.Context, string, string) (net.Conn, error)) {
+	t.Helper()
+
+	wrapper, ok := notifier.(_uptime_gopher_Notifier)
+	if !ok {
+		t.Fatalf("unexpected notifier type %T", notifier)
+	}
+
+	field := reflect.ValueOf(wrapper.IValue).Elem().FieldByName("Dial")
+	if !field.IsValid() || !field.CanSet() {
+		t.Fatal("notifier has no Dial hook")
+	}
+
+	field.Set(reflect.ValueOf(dial))
+}
+
+func TestEmailNotifier(t *testing.T) {
+	app := stdApp(t)
+
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{stubCertificate(t)}}
+
+	notification := Notification{
+		Receiver: "test",
+		Events: []Event{{
+			Job:       "example.com/http",
+			Domain:    "example.com",
+			Check:     "http",
+			CheckName: "Http Check",
+			From:      "UP",
+			To:        "DOWN",
+			Severity:  SeverityDown,
+			Message:   "status code is not as expected: 500",
+			At:        time.Now(),
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		args     map[string]string
+		implicit bool
+		auth     string
+		fails    bool
+	}{
+		{
+			name: "starttls with plain auth",
+			args: map[string]string{"tls": "starttls", "auth": "plain"},
+			auth: "PLAIN",
+		},
+		{
+			name:     "implicit tls with login auth",
+			args:     map[string]string{"tls": "implicit", "auth": "login"},
+			implicit: true,
+			auth:     "LOGIN",
+		},
+		{
+			name:  "no credentials without tls",
+			args:  map[string]string{"tls": "none", "auth": "login"},
+			fails: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := map[string]string{
+				"host":            "smtp.example.com",
+				"tls_skip_verify": "true",
+				"username":        "uptime",
+				"password":        "secret",
+				"from":            "Uptime Gopher <uptime@example.com>",
+				"to":              "Ops <ops@example.com>, oncall@example.com,dev@example.com",
+				"timeout":         "5s",
+			}
+
+			for key, value := range test.args {
+				args[key] = value
+			}
+
+			notifier := stdNotifier(t, app, "email", args)
+
+			stub := &smtpStub{tls: serverTLS, implicit: test.implicit}
+			served := make(chan error, 1)
+
+			setDial(t, notifier, func(ctx context.Context, network string, address string) (net.Conn, error) {
+				client, server := net.Pipe()
+
+				go func() {
+					served <- stub.serve(server)
+				}()
+
+				return client, nil
+			})
+
+			err := notifier.Notify(context.Background(), notification)
+
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				if stub.password != "" {
+					t.Fatal("credentials were sent without TLS")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = <-served
+			if err != nil {
+				t.Fatalf("stub: %v", err)
+			}
+
+			if !stub.secure || stub.auth != test.auth || stub.username != "uptime" || stub.password != "secret" {
+				t.Fatalf("got tls %v, auth %q as %q/%q", stub.secure, stub.auth, stub.username, stub.password)
+			}
+
+			if stub.from != "FROM:<uptime@example.com>" {
+				t.Fatalf("got MAIL %q", stub.from)
+			}
+
+			want := "TO:<ops@example.com>,TO:<oncall@example.com>,TO:<dev@example.com>"
+			if got := strings.Join(stub.rcpts, ","); got != want {
+				t.Fatalf("got RCPT %s, want %s", got, want)
+			}
+
+			for _, part := range []string{
+				"Subject: [DOWN] example.com/http",
+				"Content-Type: text/plain; charset=utf-8",
+				"Content-Type: text/html; charset=utf-8",
+				"status code is not as expected: 500",
+			} {
+				if !strings.Contains(stub.data, part) {
+					t.Fatalf("message is missing %q:\n%s", part, stub.data)
+				}
+			}
+		})
+	}
+}
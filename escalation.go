@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// escalation tracks a job that went down under one escalation policy until
+// it recovers or is acknowledged.
+type escalation struct {
+	key    string
+	policy *EscalationPolicy
+	event  Event
+
+	// step is the number of steps that fired so far.
+	step   int
+	nextAt time.Time
+	timer  *time.Timer
+
+	acknowledged   bool
+	acknowledgedBy string
+	acknowledgedAt time.Time
+	comment        string
+}
+
+// EscalationStatus describes a running escalation for the control API.
+type EscalationStatus struct {
+	Job     string     `json:"job"`
+	Policy  string     `json:"policy"`
+	Step    int        `json:"step"`
+	Steps   int        `json:"steps"`
+	Started time.Time  `json:"started"`
+	NextAt  *time.Time `json:"next_at,omitempty"`
+
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	Comment        string     `json:"comment,omitempty"`
+}
+
+// compileEscalations checks the escalation policies of config and returns
+// them by name.
+func compileEscalations(config Config) (map[string]*EscalationPolicy, error) {
+	names := map[string]bool{}
+	for _, notifierConfig := range config.Notifiers {
+		names[notifierConfig.Name] = true
+	}
+
+	policies := map[string]*EscalationPolicy{}
+
+	for i := range config.EscalationPolicies {
+		policy := &config.EscalationPolicies[i]
+
+		if policy.Name == "" {
+			return nil, fmt.Errorf("escalation policy %d needs a name", i+1)
+		}
+
+		if _, ok := policies[policy.Name]; ok {
+			return nil, fmt.Errorf("escalation policy %s is configured twice", policy.Name)
+		}
+
+		if len(policy.Steps) == 0 {
+			return nil, fmt.Errorf("escalation policy %s: needs at least one step", policy.Name)
+		}
+
+		for j, step := range policy.Steps {
+			if step.Delay < 0 {
+				return nil, fmt.Errorf("escalation policy %s: step %d: delay must not be negative", policy.Name, j+1)
+			}
+
+			if len(step.Notifiers) == 0 {
+				return nil, fmt.Errorf("escalation policy %s: step %d: needs notifiers", policy.Name, j+1)
+			}
+
+			for _, name := range step.Notifiers {
+				if !names[name] {
+					return nil, fmt.Errorf("escalation policy %s: step %d: unknown notifier %s", policy.Name, j+1, name)
+				}
+			}
+		}
+
+		policies[policy.Name] = policy
+	}
+
+	for _, domain := range config.Domains {
+		if domain.Escalation != "" && policies[domain.Escalation] == nil {
+			return nil, fmt.Errorf("domain %s: unknown escalation policy %s", domain.Domain, domain.Escalation)
+		}
+	}
+
+	return policies, nil
+}
+
+func domainEscalations(config Config) map[string]string {
+	escalations := map[string]string{}
+
+	for _, domain := range config.Domains {
+		if domain.Escalation != "" {
+			escalations[domain.Domain] = domain.Escalation
+		}
+	}
+
+	return escalations
+}
+
+// escalate starts the escalations for a job that went down, and ends them
+// once it is no longer down. The policies come from the domain of the job
+// and the routes the event matched.
+func (d *Dispatcher) escalate(event Event, routes []*routeNode) {
+	if event.To != StateDown.String() {
+		d.endEscalations(event.Job)
+
+		return
+	}
+
+	names := []string{}
+	if name := d.domainEscalations[event.Domain]; name != "" {
+		names = append(names, name)
+	}
+
+	for _, route := range routes {
+		if route.Escalation != "" && !slices.Contains(names, route.Escalation) {
+			names = append(names, route.Escalation)
+		}
+	}
+
+	for _, name := range names {
+		key := event.Job + "\x00" + name
+		if _, ok := d.escalations[key]; ok {
+			continue
+		}
+
+		esc := &escalation{
+			key:    key,
+			policy: d.policies[name],
+			event:  event,
+		}
+
+		d.escalations[key] = esc
+
+		d.scheduleStep(esc)
+	}
+}
+
+func (d *Dispatcher) scheduleStep(esc *escalation) {
+	delay := esc.policy.Steps[esc.step].Delay
+
+	esc.nextAt = time.Now().Add(delay)
+	esc.timer = time.AfterFunc(delay, func() {
+		d.fireStep(esc)
+	})
+}
+
+func (d *Dispatcher) fireStep(esc *escalation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.escalations[esc.key] != esc || esc.acknowledged {
+		return
+	}
+
+	step := esc.policy.Steps[esc.step]
+	esc.step++
+
+	d.log.Warn("Escalating", "job", esc.event.Job, "policy", esc.policy.Name, "step", esc.step, "notifiers", step.Notifiers)
+
+	for _, name := range step.Notifiers {
+		notifier, ok := d.notifiers[name]
+		if !ok {
+			d.log.Warn("Notifier no longer exists. Dropping notification", "receiver", name, "events", 1)
+
+			continue
+		}
+
+		d.deliver(notifier, Notification{
+			Receiver:   name,
+			Events:     []Event{esc.event},
+			Escalation: esc.policy.Name,
+			Step:       esc.step,
+		})
+	}
+
+	if esc.step < len(esc.policy.Steps) {
+		d.scheduleStep(esc)
+	} else {
+		esc.nextAt = time.Time{}
+	}
+}
+
+func (d *Dispatcher) endEscalations(job string) {
+	for key, esc := range d.escalations {
+		if esc.event.Job != job {
+			continue
+		}
+
+		esc.timer.Stop()
+
+		delete(d.escalations, key)
+	}
+}
+
+// Acknowledge stops the escalations of a job. The job stays acknowledged
+// until it recovers.
+func (d *Dispatcher) Acknowledge(job string, by string, comment string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	found := false
+
+	for _, esc := range d.escalations {
+		if esc.event.Job != job {
+			continue
+		}
+
+		found = true
+
+		if esc.acknowledged {
+			continue
+		}
+
+		esc.timer.Stop()
+		esc.nextAt = time.Time{}
+		esc.acknowledged = true
+		esc.acknowledgedBy = by
+		esc.acknowledgedAt = time.Now()
+		esc.comment = comment
+
+		d.log.Info("Escalation acknowledged", "job", job, "policy", esc.policy.Name, "by", by)
+	}
+
+	if !found {
+		return fmt.Errorf("no escalation for %s: %w", job, errNotFound)
+	}
+
+	return nil
+}
+
+// Escalations lists the running escalations.
+func (d *Dispatcher) Escalations() []EscalationStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	statuses := []EscalationStatus{}
+
+	for _, esc := range d.escalations {
+		status := EscalationStatus{
+			Job:     esc.event.Job,
+			Policy:  esc.policy.Name,
+			Step:    esc.step,
+			Steps:   len(esc.policy.Steps),
+			Started: esc.event.At,
+
+			Acknowledged:   esc.acknowledged,
+			AcknowledgedBy: esc.acknowledgedBy,
+			Comment:        esc.comment,
+		}
+
+		if !esc.nextAt.IsZero() {
+			nextAt := esc.nextAt
+			status.NextAt = &nextAt
+		}
+
+		if esc.acknowledged {
+			acknowledgedAt := esc.acknowledgedAt
+			status.AcknowledgedAt = &acknowledgedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	slices.SortFunc(statuses, func(a, b EscalationStatus) int {
+		return a.Started.Compare(b.Started)
+	})
+
+	return statuses
+}
+
+func (d *Dispatcher) stopEscalations() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, esc := range d.escalations {
+		esc.timer.Stop()
+
+		delete(d.escalations, key)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	notifications chan Notification
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{notifications: make(chan Notification, 16)}
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.notifications <- notification
+
+	return nil
+}
+
+// receive waits for the next notification, or fails after a second.
+func (n *recordingNotifier) receive(t *testing.T) Notification {
+	t.Helper()
+
+	select {
+	case notification := <-n.notifications:
+		return notification
+	case <-time.After(time.Second):
+		t.Fatal("no notification")
+	}
+
+	return Notification{}
+}
+
+// none checks that no notification arrives within d.
+func (n *recordingNotifier) none(t *testing.T, d time.Duration) {
+	t.Helper()
+
+	select {
+	case notification := <-n.notifications:
+		t.Fatalf("unexpected notification %s step %d", notification.Receiver, notification.Step)
+	case <-time.After(d):
+	}
+}
+
+// escalationConfig configures the oncall policy for example.com, with one
+// step per delay that notifies the notifier tier1, tier2 and so on.
+func escalationConfig(delays ...time.Duration) Config {
+	policy := EscalationPolicy{Name: "oncall"}
+	config := Config{
+		Domains: []Domain{{Domain: "example.com", Escalation: "oncall"}},
+	}
+
+	for i, delay := range delays {
+		name := fmt.Sprintf("tier%d", i+1)
+
+		config.Notifiers = append(config.Notifiers, NotifierConfig{Name: name})
+		policy.Steps = append(policy.Steps, EscalationStep{Delay: delay, Notifiers: []string{name}})
+	}
+
+	config.EscalationPolicies = []EscalationPolicy{policy}
+
+	return config
+}
+
+func testDispatcher(t *testing.T, delays ...time.Duration) (*Dispatcher, []*recordingNotifier) {
+	t.Helper()
+
+	config := escalationConfig(delays...)
+
+	notifiers := map[string]Notifier{}
+	recorders := []*recordingNotifier{}
+
+	for _, notifierConfig := range config.Notifiers {
+		recorder := newRecordingNotifier()
+
+		notifiers[notifierConfig.Name] = recorder
+		recorders = append(recorders, recorder)
+	}
+
+	d, err := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), config, notifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(d.stopEscalations)
+
+	return d, recorders
+}
+
+func escalationEvent(to State) Event {
+	return Event{
+		Job:    "example.com/http",
+		Domain: "example.com",
+		Check:  "http",
+		From:   StateUp.String(),
+		To:     to.String(),
+		At:     time.Now(),
+	}
+}
+
+func TestEscalationSteps(t *testing.T) {
+	d, tiers := testDispatcher(t, 20*time.Millisecond, 40*time.Millisecond)
+
+	started := time.Now()
+	d.route(escalationEvent(StateDown))
+
+	first := tiers[0].receive(t)
+	if elapsed := time.Since(started); elapsed < 20*time.Millisecond {
+		t.Fatalf("first step fired after %s", elapsed)
+	}
+
+	if first.Escalation != "oncall" || first.Step != 1 || first.Events[0].Job != "example.com/http" {
+		t.Fatalf("got %s step %d", first.Escalation, first.Step)
+	}
+
+	second := tiers[1].receive(t)
+	if elapsed := time.Since(started); elapsed < 60*time.Millisecond {
+		t.Fatalf("second step fired after %s", elapsed)
+	}
+
+	if second.Step != 2 {
+		t.Fatalf("got step %d, want 2", second.Step)
+	}
+
+	// A repeated DOWN event does not start the escalation again.
+	d.route(escalationEvent(StateDown))
+
+	tiers[0].none(t, 50*time.Millisecond)
+
+	statuses := d.Escalations()
+	if len(statuses) != 1 || statuses[0].Step != 2 || statuses[0].NextAt != nil {
+		t.Fatalf("got escalations %+v", statuses)
+	}
+}
+
+func TestEscalationAcknowledge(t *testing.T) {
+	d, tiers := testDispatcher(t, 0, 50*time.Millisecond)
+
+	d.route(escalationEvent(StateDown))
+
+	tiers[0].receive(t)
+
+	err := d.Acknowledge("example.com/http", "ops", "looking into it")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiers[1].none(t, 100*time.Millisecond)
+
+	statuses := d.Escalations()
+	if len(statuses) != 1 || !statuses[0].Acknowledged || statuses[0].AcknowledgedBy != "ops" || statuses[0].NextAt != nil {
+		t.Fatalf("got escalations %+v", statuses)
+	}
+
+	err = d.Acknowledge("example.com/dns", "ops", "")
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("got %v for a job without escalation", err)
+	}
+}
+
+func TestEscalationEndsOnRecovery(t *testing.T) {
+	d, tiers := testDispatcher(t, 30*time.Millisecond)
+
+	d.route(escalationEvent(StateDown))
+	d.route(escalationEvent(StateUp))
+
+	tiers[0].none(t, 60*time.Millisecond)
+
+	if statuses := d.Escalations(); len(statuses) != 0 {
+		t.Fatalf("got escalations %+v after recovery", statuses)
+	}
+}
+
+func TestEscalationEndsOnRecoveryDuringMaintenance(t *testing.T) {
+	now := time.Now()
+
+	job, err := NewJob(Domain{Domain: "example.com", Escalation: "oncall"}, Check{Name: "Test"}, CheckConfig{Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testScheduler(t, escalationConfig(time.Hour), &memoryStore{}, job)
+	t.Cleanup(s.dispatcher.stopEscalations)
+
+	handle := func() {
+		for _, event := range queuedEvents(s) {
+			s.dispatcher.handle(event)
+		}
+	}
+
+	s.handleResult(jobResult{job: job, result: CheckResult{Success: false, Severity: SeverityDown}, started: now})
+	handle()
+
+	if len(s.dispatcher.Escalations()) != 1 {
+		t.Fatal("outage did not start an escalation")
+	}
+
+	window, err := NewMaintenance(MaintenanceWindow{Name: "deploy", Start: now.Format(time.RFC3339), Duration: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.maintenance = append(s.maintenance, window)
+	s.pause(job, now)
+
+	s.handleResult(jobResult{job: job, result: CheckResult{Success: true}, started: now.Add(time.Minute)})
+
+	events := queuedEvents(s)
+	if len(events) != 1 || !events[0].muted || events[0].To != StateUp.String() {
+		t.Fatalf("got events %+v, want a muted recovery", events)
+	}
+
+	s.dispatcher.handle(events[0])
+
+	if statuses := s.dispatcher.Escalations(); len(statuses) != 0 {
+		t.Fatalf("got escalations %+v after a recovery during maintenance", statuses)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHttpCheck(t *testing.T) {
+	app := stdApp(t)
+
+	check, err := app.NewCheck("http")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		success bool
+		status  float64
+	}{
+		{"/", true, 200},
+		{"/broken", false, 500},
+	}
+
+	for _, test := range tests {
+		result := check.run(context.Background(), server.URL+test.path, map[string]string{})
+		if result.Success != test.success {
+			t.Fatalf("%s: got success %v: %s", test.path, result.Success, result.Message)
+		}
+
+		if result.Metrics["status_code"] != test.status {
+			t.Fatalf("%s: got status %v, want %v", test.path, result.Metrics["status_code"], test.status)
+		}
+
+		if _, ok := result.Metrics["ttfb_seconds"]; !ok {
+			t.Fatalf("%s: no time to first byte in %v", test.path, result.Metrics)
+		}
+
+		// Plain HTTP has no handshake.
+		if _, ok := result.Metrics["tls_handshake_seconds"]; ok {
+			t.Fatalf("%s: got a TLS handshake time over plain HTTP", test.path)
+		}
+	}
+}
+
+func TestHttpCheckRejectsRemovedArgs(t *testing.T) {
+	app := stdApp(t)
+
+	check, err := app.GetCheck("http")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args map[string]string
+		want string
+	}{
+		{map[string]string{"timeout": "5s"}, "set the timeout of the check"},
+		{map[string]string{"retries": "3"}, "set the failure_threshold of the check"},
+		{map[string]string{"method": "GET", "success_code": "204"}, ""},
+	}
+
+	for _, test := range tests {
+		err := check.ValidateArgs(test.args)
+
+		if test.want == "" && err != nil {
+			t.Fatalf("%v: got %v", test.args, err)
+		}
+
+		if test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Fatalf("%v: got %v, want %q", test.args, err, test.want)
+		}
+	}
+}
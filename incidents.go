@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const incidentsFile = "incidents.json"
+
+const (
+	NoteOpened       = "opened"
+	NoteResult       = "result"
+	NoteState        = "state"
+	NoteAcknowledged = "acknowledged"
+	NoteComment      = "note"
+	NoteResolved     = "resolved"
+)
+
+// Incident is opened when a job goes DOWN and resolved when it recovers.
+// Every result of the job in between is counted against it, and results
+// with a new message are added to the timeline.
+type Incident struct {
+	ID     int64  `json:"id"`
+	Job    string `json:"job"`
+	Domain string `json:"domain"`
+	Check  string `json:"check"`
+
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Results  int      `json:"results"`
+
+	StartedAt      time.Time  `json:"started_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+
+	Timeline []IncidentNote `json:"timeline"`
+}
+
+type IncidentNote struct {
+	At       time.Time `json:"at"`
+	Kind     string    `json:"kind"`
+	By       string    `json:"by,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Severity *Severity `json:"severity,omitempty"`
+}
+
+// Duration is the time from the start of the incident to its resolution, or
+// to now while it is open.
+func (i *Incident) Duration(now time.Time) time.Duration {
+	if i.ResolvedAt != nil {
+		return i.ResolvedAt.Sub(i.StartedAt)
+	}
+
+	return now.Sub(i.StartedAt)
+}
+
+// IncidentStatus is an incident as the control API reports it.
+type IncidentStatus struct {
+	Incident
+
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+func (i *Incident) Status(now time.Time) IncidentStatus {
+	return IncidentStatus{
+		Incident:        *i,
+		DurationSeconds: i.Duration(now).Seconds(),
+	}
+}
+
+// IncidentStore keeps the incidents in memory and writes them to a JSON file
+// on every change, so open incidents survive a restart. Resolved incidents
+// are dropped once they are older than the retention.
+type IncidentStore struct {
+	log *slog.Logger
+
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	nextID    int64
+	incidents []*Incident
+	open      map[string]*Incident
+}
+
+type incidentsSnapshot struct {
+	NextID    int64       `json:"next_id"`
+	Incidents []*Incident `json:"incidents"`
+}
+
+func NewIncidentStore(log *slog.Logger, dir string, retention time.Duration) (*IncidentStore, error) {
+	log = log.With("service", "Incidents")
+
+	store := &IncidentStore{
+		log: log,
+
+		path:      filepath.Join(dir, incidentsFile),
+		retention: retention,
+		nextID:    1,
+		incidents: []*Incident{},
+		open:      map[string]*Incident{},
+	}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot incidentsSnapshot
+
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", store.path, err)
+	}
+
+	store.nextID = max(snapshot.NextID, 1)
+
+	for _, incident := range snapshot.Incidents {
+		store.incidents = append(store.incidents, incident)
+
+		if incident.ResolvedAt == nil {
+			store.open[incident.Job] = incident
+		}
+	}
+
+	store.prune(time.Now())
+
+	log.Info("Incidents loaded", "total", len(store.incidents), "open", len(store.open))
+
+	return store, nil
+}
+
+// Observe counts a result against the open incident of the job, and opens or
+// resolves the incident on a state change. It returns the ID of the incident
+// the result belongs to, or 0.
+func (s *IncidentStore) Observe(job *Job, result CheckResult, change *StateChange, at time.Time) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.open[job.Key()]
+	dirty := false
+
+	if incident == nil {
+		if change == nil || change.To != StateDown {
+			return 0
+		}
+
+		incident = &Incident{
+			ID:     s.nextID,
+			Job:    job.Key(),
+			Domain: job.domain.Domain,
+			Check:  job.checkConfig.Key,
+
+			Severity: result.Severity,
+			Message:  result.Message,
+
+			StartedAt: at,
+			Timeline: []IncidentNote{{
+				At:       at,
+				Kind:     NoteOpened,
+				Text:     result.Message,
+				Severity: &result.Severity,
+			}},
+		}
+
+		s.nextID++
+		s.incidents = append(s.incidents, incident)
+		s.open[incident.Job] = incident
+		dirty = true
+
+		s.log.Warn("Incident opened", "id", incident.ID, "job", incident.Job, "message", result.Message)
+	} else if last := incident.lastResult(); !result.Success && (last.Text != result.Message || *last.Severity != result.Severity) {
+		incident.Timeline = append(incident.Timeline, IncidentNote{
+			At:       at,
+			Kind:     NoteResult,
+			Text:     result.Message,
+			Severity: &result.Severity,
+		})
+
+		dirty = true
+	}
+
+	incident.Results++
+
+	if result.Severity > incident.Severity {
+		incident.Severity = result.Severity
+	}
+
+	if change != nil && change.To != StateDown {
+		if change.To == StateUp {
+			incident.ResolvedAt = &at
+			incident.Timeline = append(incident.Timeline, IncidentNote{
+				At:   at,
+				Kind: NoteResolved,
+			})
+
+			delete(s.open, incident.Job)
+
+			s.log.Info("Incident resolved", "id", incident.ID, "job", incident.Job, "duration", incident.Duration(at))
+		} else {
+			incident.Timeline = append(incident.Timeline, IncidentNote{
+				At:   at,
+				Kind: NoteState,
+				Text: change.To.String(),
+			})
+		}
+
+		dirty = true
+	}
+
+	if dirty {
+		s.save()
+	}
+
+	return incident.ID
+}
+
+func (i *Incident) lastResult() IncidentNote {
+	for j := len(i.Timeline) - 1; j >= 0; j-- {
+		if i.Timeline[j].Severity != nil {
+			return i.Timeline[j]
+		}
+	}
+
+	return IncidentNote{Severity: new(Severity)}
+}
+
+// Close resolves the open incident of a job that no longer exists.
+func (s *IncidentStore) Close(job string, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.close(job, reason) {
+		s.save()
+	}
+}
+
+// CloseMissing resolves the open incidents of jobs that were removed from the
+// config while the process was not running.
+func (s *IncidentStore) CloseMissing(jobs []*Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := jobsByKey(jobs)
+	closed := false
+
+	for job := range s.open {
+		if keys[job] == nil {
+			closed = s.close(job, "job removed from config") || closed
+		}
+	}
+
+	if closed {
+		s.save()
+	}
+}
+
+func (s *IncidentStore) close(job string, reason string) bool {
+	incident := s.open[job]
+	if incident == nil {
+		return false
+	}
+
+	now := time.Now()
+
+	incident.ResolvedAt = &now
+	incident.Timeline = append(incident.Timeline, IncidentNote{
+		At:   now,
+		Kind: NoteResolved,
+		Text: reason,
+	})
+
+	delete(s.open, job)
+
+	s.log.Info("Incident resolved", "id", incident.ID, "job", job, "reason", reason)
+
+	return true
+}
+
+// Acknowledge marks an open incident as acknowledged. It returns the job of
+// the incident.
+func (s *IncidentStore) Acknowledge(id int64, by string, comment string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.find(id)
+	if incident == nil {
+		return "", fmt.Errorf("incident %d: %w", id, errNotFound)
+	}
+
+	if incident.ResolvedAt != nil {
+		return "", fmt.Errorf("incident %d is already resolved", id)
+	}
+
+	s.acknowledge(incident, by, comment)
+
+	return incident.Job, nil
+}
+
+// AcknowledgeJob acknowledges the open incident of a job, if there is one.
+func (s *IncidentStore) AcknowledgeJob(job string, by string, comment string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.open[job]
+	if incident == nil {
+		return false
+	}
+
+	s.acknowledge(incident, by, comment)
+
+	return true
+}
+
+func (s *IncidentStore) acknowledge(incident *Incident, by string, comment string) {
+	now := time.Now()
+
+	if incident.AcknowledgedAt == nil {
+		incident.AcknowledgedAt = &now
+		incident.AcknowledgedBy = by
+	}
+
+	incident.Timeline = append(incident.Timeline, IncidentNote{
+		At:   now,
+		Kind: NoteAcknowledged,
+		By:   by,
+		Text: comment,
+	})
+
+	s.log.Info("Incident acknowledged", "id", incident.ID, "job", incident.Job, "by", by)
+
+	s.save()
+}
+
+// AddNote adds a note to the timeline of an incident.
+func (s *IncidentStore) AddNote(id int64, by string, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.find(id)
+	if incident == nil {
+		return fmt.Errorf("incident %d: %w", id, errNotFound)
+	}
+
+	incident.Timeline = append(incident.Timeline, IncidentNote{
+		At:   time.Now(),
+		Kind: NoteComment,
+		By:   by,
+		Text: text,
+	})
+
+	s.save()
+
+	return nil
+}
+
+// List returns copies of the incidents, newest first. Resolved incidents are
+// only included if all is set.
+func (s *IncidentStore) List(all bool) []Incident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incidents := []Incident{}
+
+	for i := len(s.incidents) - 1; i >= 0; i-- {
+		incident := s.incidents[i]
+
+		if all || incident.ResolvedAt == nil {
+			incidents = append(incidents, incident.copy())
+		}
+	}
+
+	return incidents
+}
+
+func (s *IncidentStore) Get(id int64) (Incident, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.find(id)
+	if incident == nil {
+		return Incident{}, fmt.Errorf("incident %d: %w", id, errNotFound)
+	}
+
+	return incident.copy(), nil
+}
+
+func (s *IncidentStore) find(id int64) *Incident {
+	i, ok := slices.BinarySearchFunc(s.incidents, id, func(incident *Incident, id int64) int {
+		return int(incident.ID - id)
+	})
+	if !ok {
+		return nil
+	}
+
+	return s.incidents[i]
+}
+
+func (i *Incident) copy() Incident {
+	incident := *i
+	incident.Timeline = slices.Clone(i.Timeline)
+
+	return incident
+}
+
+// Save writes the incidents to disk. Results only change the counters of an
+// incident, so they are written on the next change or here on shutdown.
+func (s *IncidentStore) Save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.save()
+}
+
+// save writes the incidents to a temporary file and renames it over the old
+// one, so a crash never leaves a partial file behind.
+func (s *IncidentStore) save() {
+	s.prune(time.Now())
+
+	data, err := json.MarshalIndent(incidentsSnapshot{
+		NextID:    s.nextID,
+		Incidents: s.incidents,
+	}, "", "  ")
+	if err != nil {
+		s.log.Error("Failed to encode incidents", "error", err)
+
+		return
+	}
+
+	tmp := s.path + ".tmp"
+
+	err = os.WriteFile(tmp, data, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+
+	if err != nil {
+		s.log.Error("Failed to save incidents", "path", s.path, "error", err)
+	}
+}
+
+// prune drops the incidents resolved before the retention. Stored results
+// refer to incidents by ID, so they use the retention of the results.
+func (s *IncidentStore) prune(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-s.retention)
+	total := len(s.incidents)
+
+	s.incidents = slices.DeleteFunc(s.incidents, func(incident *Incident) bool {
+		return incident.ResolvedAt != nil && incident.ResolvedAt.Before(cutoff)
+	})
+
+	if pruned := total - len(s.incidents); pruned > 0 {
+		s.log.Info("Resolved incidents pruned", "count", pruned, "retention", s.retention)
+	}
+}
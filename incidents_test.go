@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestIncidentStore(t *testing.T, dir string, retention time.Duration) *IncidentStore {
+	t.Helper()
+
+	store, err := NewIncidentStore(slog.New(slog.NewTextHandler(io.Discard, nil)), dir, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func failure(message string) CheckResult {
+	return CheckResult{Success: false, Severity: SeverityDown, Message: message}
+}
+
+func noteKinds(incident Incident) string {
+	kinds := []string{}
+	for _, note := range incident.Timeline {
+		kinds = append(kinds, note.Kind)
+	}
+
+	return strings.Join(kinds, ",")
+}
+
+func TestIncidentLifecycle(t *testing.T) {
+	store := openTestIncidentStore(t, t.TempDir(), 0)
+	job := testJob(t, time.Minute)
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if id := store.Observe(job, failure("timeout"), nil, at); id != 0 {
+		t.Fatalf("result without an outage opened incident %d", id)
+	}
+
+	id := store.Observe(job, failure("timeout"), &StateChange{From: StateUp, To: StateDown}, at)
+	if id != 1 {
+		t.Fatalf("got incident %d, want 1", id)
+	}
+
+	// The same message is only counted, a new one is added to the timeline.
+	store.Observe(job, failure("timeout"), nil, at.Add(time.Minute))
+	store.Observe(job, failure("connection refused"), nil, at.Add(2*time.Minute))
+
+	degraded := CheckResult{Success: false, Severity: SeverityWarning, Message: "slow"}
+	if got := store.Observe(job, degraded, &StateChange{From: StateDown, To: StateDegraded}, at.Add(3*time.Minute)); got != id {
+		t.Fatalf("result counted against incident %d, want %d", got, id)
+	}
+
+	if len(store.List(false)) != 1 {
+		t.Fatal("incident closed before the job recovered")
+	}
+
+	store.Observe(job, CheckResult{Success: true}, &StateChange{From: StateDegraded, To: StateUp}, at.Add(4*time.Minute))
+
+	if len(store.List(false)) != 0 {
+		t.Fatal("incident still open after recovery")
+	}
+
+	incident, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := noteKinds(incident); got != "opened,result,result,state,resolved" {
+		t.Fatalf("got timeline %s", got)
+	}
+
+	if incident.Results != 5 || incident.Severity != SeverityDown || incident.Duration(time.Now()) != 4*time.Minute {
+		t.Fatalf("got %d results, severity %s and duration %s", incident.Results, incident.Severity, incident.Duration(time.Now()))
+	}
+
+	// The next outage opens a new incident.
+	if got := store.Observe(job, failure("timeout"), &StateChange{From: StateUp, To: StateDown}, at.Add(time.Hour)); got != 2 {
+		t.Fatalf("got incident %d, want 2", got)
+	}
+}
+
+func TestIncidentStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestIncidentStore(t, dir, 0)
+
+	a := testJob(t, time.Minute)
+	b, err := NewJob(Domain{Domain: "example.org"}, Check{Name: "Test"}, CheckConfig{Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	store.Observe(a, failure("timeout"), &StateChange{To: StateDown}, now)
+	store.Observe(b, failure("timeout"), &StateChange{To: StateDown}, now)
+	store.Observe(b, CheckResult{Success: true}, &StateChange{To: StateUp}, now)
+
+	_, err = store.Acknowledge(1, "ops", "looking into it")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.AddNote(1, "ops", "rolled back")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store = openTestIncidentStore(t, dir, 0)
+
+	if len(store.List(true)) != 2 {
+		t.Fatalf("got %d incidents after reload, want 2", len(store.List(true)))
+	}
+
+	open := store.List(false)
+	if len(open) != 1 || open[0].ID != 1 || open[0].AcknowledgedBy != "ops" || noteKinds(open[0]) != "opened,acknowledged,note" {
+		t.Fatalf("got open incidents %+v", open)
+	}
+
+	// The open incident is still tracked and the IDs carry on.
+	if got := store.Observe(a, CheckResult{Success: true}, &StateChange{To: StateUp}, now); got != 1 {
+		t.Fatalf("recovery counted against incident %d, want 1", got)
+	}
+
+	if got := store.Observe(b, failure("timeout"), &StateChange{To: StateDown}, now); got != 3 {
+		t.Fatalf("got incident %d after reload, want 3", got)
+	}
+
+	store.CloseMissing([]*Job{a})
+
+	if len(store.List(false)) != 0 {
+		t.Fatal("incident of a removed job still open")
+	}
+}
+
+func TestIncidentStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestIncidentStore(t, dir, 24*time.Hour)
+
+	old := testJob(t, time.Minute)
+	recent, err := NewJob(Domain{Domain: "example.org"}, Check{Name: "Test"}, CheckConfig{Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ongoing, err := NewJob(Domain{Domain: "example.net"}, Check{Name: "Test"}, CheckConfig{Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	// An outage that started before the retention and is still open is kept.
+	store.Observe(ongoing, failure("timeout"), &StateChange{To: StateDown}, now.Add(-72*time.Hour))
+
+	store.Observe(old, failure("timeout"), &StateChange{To: StateDown}, now.Add(-49*time.Hour))
+	store.Observe(old, CheckResult{Success: true}, &StateChange{To: StateUp}, now.Add(-48*time.Hour))
+
+	store.Observe(recent, failure("timeout"), &StateChange{To: StateDown}, now.Add(-2*time.Hour))
+	store.Observe(recent, CheckResult{Success: true}, &StateChange{To: StateUp}, now.Add(-time.Hour))
+
+	ids := func(store *IncidentStore) []int64 {
+		ids := []int64{}
+		for _, incident := range store.List(true) {
+			ids = append(ids, incident.ID)
+		}
+
+		return ids
+	}
+
+	if got := ids(store); len(got) != 2 || got[0] != 3 || got[1] != 1 {
+		t.Fatalf("got incidents %v, want 3 and 1", got)
+	}
+
+	if _, err := store.Get(2); err == nil {
+		t.Fatal("pruned incident still found")
+	}
+
+	store = openTestIncidentStore(t, dir, 24*time.Hour)
+
+	if got := ids(store); len(got) != 2 || got[0] != 3 || got[1] != 1 {
+		t.Fatalf("got incidents %v after reload, want 3 and 1", got)
+	}
+}
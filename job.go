@@ -0,0 +1,266 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"time"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultTimeout  = 30 * time.Second
+)
+
+type Job struct {
+	domain      Domain
+	check       Check
+	checkConfig CheckConfig
+	next        time.Time
+
+	// index is the position of the job in the scheduler queue. Jobs waiting
+	// for a worker or being executed are not in the queue, so the same job is
+	// never dispatched twice at once.
+	index int
+
+	// running is set while the job is waiting for a worker or being executed.
+	running bool
+
+	// removed is set when a reload drops the job while it is running, so its
+	// result is discarded instead of rescheduling it.
+	removed bool
+
+	// replacedBy is the job that replaced this one in a reload while it was
+	// running. The replacement is held back until this job comes back, so
+	// the same check never runs twice at once.
+	replacedBy *Job
+
+	// schedule is set when the check runs on a cron expression instead of an
+	// interval.
+	schedule *CronSchedule
+
+	state      State
+	stateSince time.Time
+	successes  int
+	failures   int
+	lastResult CheckResult
+	lastRun    time.Time
+
+	// streak counts the latest results in a row that point towards
+	// streakState. A state change needs a streak as long as its threshold.
+	streak      int
+	streakState State
+
+	history       []State
+	flapping      bool
+	flappingSince time.Time
+
+	// dependsOn holds the keys of the jobs this job depends on. While one of
+	// them is down the job is suppressed: it is skipped and keeps its state.
+	dependsOn  []string
+	suppressed bool
+
+	// alertSeverity is the severity of the last problem that was alerted, so
+	// the recovery is routed like the problem it resolves.
+	alertSeverity Severity
+
+	// maintenance names the maintenance window the job is in.
+	maintenance string
+
+	quarantined      bool
+	quarantinedAt    time.Time
+	quarantineReason string
+
+	// rand is seeded from the domain and check key, so the splay and jitter
+	// offsets of a job are the same on every start.
+	rand *rand.Rand
+}
+
+func NewJob(domain Domain, check Check, checkConfig CheckConfig) (*Job, error) {
+	var schedule *CronSchedule
+	if checkConfig.Schedule != "" {
+		var err error
+
+		schedule, err = ParseCron(checkConfig.Schedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seed := fnv.New64a()
+	seed.Write([]byte(domain.Domain))
+	seed.Write([]byte{0})
+	seed.Write([]byte(checkConfig.Key))
+
+	return &Job{
+		domain:      domain,
+		check:       check,
+		checkConfig: checkConfig,
+		index:       -1,
+
+		schedule: schedule,
+		rand:     rand.New(rand.NewPCG(seed.Sum64(), 0)),
+	}, nil
+}
+
+// Key identifies the job across config reloads.
+func (j *Job) Key() string {
+	return jobKey(j.domain, j.checkConfig)
+}
+
+// sameConfig reports whether other was built from the same domain and check
+// settings.
+func (j *Job) sameConfig(other *Job) bool {
+	domain, otherDomain := j.domain, other.domain
+	domain.Checks, otherDomain.Checks = nil, nil
+
+	return reflect.DeepEqual(domain, otherDomain) && reflect.DeepEqual(j.checkConfig, other.checkConfig)
+}
+
+// inherit carries the state of a job over to the job replacing it.
+func (j *Job) inherit(prev *Job) {
+	j.state = prev.state
+	j.stateSince = prev.stateSince
+	j.successes = prev.successes
+	j.failures = prev.failures
+	j.streak = prev.streak
+	j.streakState = prev.streakState
+	j.lastResult = prev.lastResult
+	j.lastRun = prev.lastRun
+	j.history = prev.history
+	j.flapping = prev.flapping
+	j.flappingSince = prev.flappingSince
+	j.alertSeverity = prev.alertSeverity
+}
+
+// Tags returns the tags of the domain followed by those of the check.
+func (j *Job) Tags() []string {
+	return slices.Concat(j.domain.Tags, j.checkConfig.Tags)
+}
+
+func (j *Job) release() {
+	j.quarantined = false
+	j.quarantinedAt = time.Time{}
+	j.quarantineReason = ""
+}
+
+// Start sets the first run of the job. With splay the first run is spread
+// over the job interval instead of happening right away. Scheduled jobs wait
+// for their first matching time.
+func (j *Job) Start(now time.Time, splay bool) {
+	j.next = now
+
+	if j.schedule != nil {
+		j.next = j.nextRun(now)
+
+		return
+	}
+
+	if splay {
+		j.next = now.Add(time.Duration(j.rand.Int64N(int64(j.interval()))))
+	}
+}
+
+// nextRun returns when the job should run again after a run started at from.
+// While the job is failing it runs on its failure interval, if one is set.
+func (j *Job) nextRun(from time.Time) time.Time {
+	if j.failures > 0 && j.checkConfig.FailureInterval > 0 {
+		return from.Add(j.failureInterval() + j.jitter())
+	}
+
+	if j.schedule != nil {
+		return j.schedule.Next(from).Add(j.jitter())
+	}
+
+	return from.Add(j.interval() + j.jitter())
+}
+
+// span returns how long a result of a run started at at stands for at most:
+// until the next regular run, delayed by the full jitter.
+func (j *Job) span(at time.Time) time.Duration {
+	return j.regularSpan(at) + j.maxJitter()
+}
+
+// expectedSpan returns how long a result stands for when the next one is not
+// known yet: until the next regular run, delayed by the average jitter.
+func (j *Job) expectedSpan(at time.Time) time.Duration {
+	return j.regularSpan(at) + j.maxJitter()/2
+}
+
+func (j *Job) regularSpan(at time.Time) time.Duration {
+	if j.schedule != nil {
+		if next := j.schedule.Next(at); !next.IsZero() {
+			return next.Sub(at)
+		}
+	}
+
+	return j.interval()
+}
+
+func (j *Job) interval() time.Duration {
+	if j.checkConfig.Interval > 0 {
+		return j.checkConfig.Interval
+	}
+
+	if j.domain.Interval > 0 {
+		return j.domain.Interval
+	}
+
+	return defaultInterval
+}
+
+// failureInterval grows the failure interval by the backoff factor for every
+// consecutive failure after the first. It is capped by failure_interval_max,
+// or by the normal interval when no cap is set.
+func (j *Job) failureInterval() time.Duration {
+	limit := j.checkConfig.FailureIntervalMax
+	if limit <= 0 {
+		limit = max(j.interval(), j.checkConfig.FailureInterval)
+	}
+
+	interval := float64(j.checkConfig.FailureInterval)
+	if j.checkConfig.FailureBackoff > 1 && j.failures > 1 {
+		// Stop growing once the cap is reached, so the power can't overflow
+		// during a long outage.
+		steps := math.Log(float64(limit)/interval) / math.Log(j.checkConfig.FailureBackoff)
+		if float64(j.failures-1) >= steps {
+			return limit
+		}
+
+		interval *= math.Pow(j.checkConfig.FailureBackoff, float64(j.failures-1))
+	}
+
+	return min(time.Duration(interval), limit)
+}
+
+func (j *Job) timeout() time.Duration {
+	if j.checkConfig.Timeout > 0 {
+		return j.checkConfig.Timeout
+	}
+
+	if j.domain.Timeout > 0 {
+		return j.domain.Timeout
+	}
+
+	return defaultTimeout
+}
+
+func (j *Job) jitter() time.Duration {
+	jitter := j.maxJitter()
+	if jitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(j.rand.Int64N(int64(jitter)))
+}
+
+// maxJitter is the longest delay jitter adds to a run.
+func (j *Job) maxJitter() time.Duration {
+	if j.checkConfig.Jitter > 0 {
+		return j.checkConfig.Jitter
+	}
+
+	return max(j.domain.Jitter, 0)
+}
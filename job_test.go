@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestJobFailureInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   CheckConfig
+		failures int
+		want     time.Duration
+	}{
+		{
+			name:     "no backoff",
+			config:   CheckConfig{FailureInterval: 10 * time.Second},
+			failures: 5,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "first failure",
+			config:   CheckConfig{FailureInterval: 10 * time.Second, FailureBackoff: 2, FailureIntervalMax: 10 * time.Minute},
+			failures: 1,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "growth",
+			config:   CheckConfig{FailureInterval: 10 * time.Second, FailureBackoff: 2, FailureIntervalMax: 10 * time.Minute},
+			failures: 4,
+			want:     80 * time.Second,
+		},
+		{
+			name:     "fractional growth",
+			config:   CheckConfig{FailureInterval: 10 * time.Second, FailureBackoff: 1.5, FailureIntervalMax: 10 * time.Minute},
+			failures: 3,
+			want:     22500 * time.Millisecond,
+		},
+		{
+			name:     "capped by failure_interval_max",
+			config:   CheckConfig{FailureInterval: 10 * time.Second, FailureBackoff: 2, FailureIntervalMax: 10 * time.Minute},
+			failures: 8,
+			want:     10 * time.Minute,
+		},
+		{
+			name:     "capped by the interval",
+			config:   CheckConfig{Interval: time.Minute, FailureInterval: 10 * time.Second, FailureBackoff: 2},
+			failures: 4,
+			want:     time.Minute,
+		},
+		{
+			name:     "capped by a failure interval above the interval",
+			config:   CheckConfig{Interval: time.Minute, FailureInterval: 2 * time.Minute, FailureBackoff: 2},
+			failures: 3,
+			want:     2 * time.Minute,
+		},
+		{
+			name:     "long outage",
+			config:   CheckConfig{FailureInterval: 10 * time.Second, FailureBackoff: 2, FailureIntervalMax: time.Hour},
+			failures: math.MaxInt32,
+			want:     time.Hour,
+		},
+		{
+			name:     "long outage with a small backoff",
+			config:   CheckConfig{FailureInterval: 10 * time.Second, FailureBackoff: 1.0001, FailureIntervalMax: time.Hour},
+			failures: math.MaxInt32,
+			want:     time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &Job{checkConfig: test.config, failures: test.failures}
+
+			if got := job.failureInterval(); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJobSplayAndJitterAreDeterministic(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	domain := Domain{Domain: "example.com", Jitter: 10 * time.Second}
+	config := CheckConfig{Key: "http", Interval: 5 * time.Minute}
+
+	newJob := func(domain Domain, config CheckConfig) *Job {
+		job, err := NewJob(domain, Check{}, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		job.Start(now, true)
+
+		return job
+	}
+
+	a, b := newJob(domain, config), newJob(domain, config)
+
+	if !a.next.Equal(b.next) {
+		t.Fatalf("splay differs between starts: %s and %s", a.next, b.next)
+	}
+
+	for _, key := range []string{"http", "dns", "ssl", "domain"} {
+		job := newJob(domain, CheckConfig{Key: key, Interval: config.Interval})
+		if job.next.Before(now) || !job.next.Before(now.Add(config.Interval)) {
+			t.Fatalf("%s: first run at %s is outside the interval after %s", key, job.next, now)
+		}
+
+		if key != config.Key && job.next.Equal(a.next) {
+			t.Fatalf("%s: got the same splay as %s", key, config.Key)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		nextA, nextB := a.nextRun(a.next), b.nextRun(b.next)
+		if !nextA.Equal(nextB) {
+			t.Fatalf("run %d: jitter differs between starts: %s and %s", i, nextA, nextB)
+		}
+
+		delay := nextA.Sub(a.next)
+		if delay < config.Interval || delay >= config.Interval+domain.Jitter {
+			t.Fatalf("run %d: got %s until the next run", i, delay)
+		}
+
+		a.next, b.next = nextA, nextB
+	}
+}
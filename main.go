@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,15 +11,12 @@ import (
 	"time"
 
 	"github.com/lmittmann/tint"
-	"gopkg.in/yaml.v3"
 )
 
-type Job struct {
-	domain      Domain
-	check       Check
-	checkConfig CheckConfig
-	next        time.Time
-}
+const (
+	configPath     = "config.yaml"
+	defaultDataDir = "data"
+)
 
 type PluginCtx struct {
 	id  string
@@ -28,6 +27,14 @@ func (p *PluginCtx) AddCheck(check Check) {
 	p.app.AddCheck(p.id, check)
 }
 
+func (p *PluginCtx) AddCheckFactory(factory CheckFactory) {
+	p.app.AddCheckFactory(p.id, factory)
+}
+
+func (p *PluginCtx) AddNotifier(kind string, factory NotifierFactory) {
+	p.app.AddNotifier(p.id, kind, factory)
+}
+
 type Plugin interface {
 	Id() string
 	Name() string
@@ -39,8 +46,13 @@ type Plugin interface {
 type App struct {
 	log *slog.Logger
 
-	plugins []Plugin
-	checks  map[string]map[string]Check
+	plugins   []Plugin
+	checks    map[string]map[string]Check
+	factories map[string]CheckFactory
+	notifiers map[string]NotifierFactory
+
+	// loadErrors is the number of plugins that failed to load.
+	loadErrors int
 }
 
 func NewApp(log *slog.Logger) *App {
@@ -49,8 +61,10 @@ func NewApp(log *slog.Logger) *App {
 	return &App{
 		log: log,
 
-		plugins: []Plugin{},
-		checks:  map[string]map[string]Check{},
+		plugins:   []Plugin{},
+		checks:    map[string]map[string]Check{},
+		factories: map[string]CheckFactory{},
+		notifiers: map[string]NotifierFactory{},
 	}
 }
 
@@ -74,7 +88,39 @@ func (a *App) AddPlugin(plugin Plugin) error {
 	return nil
 }
 
+// Shutdown calls Shutdown on every plugin in reverse load order and returns
+// the joined errors of the plugins that failed.
+func (a *App) Shutdown() error {
+	errs := []error{}
+
+	for i := len(a.plugins) - 1; i >= 0; i-- {
+		plugin := a.plugins[i]
+
+		a.log.Info("Shutting down plugin", "name", plugin.Name())
+
+		ctx := PluginCtx{
+			id:  plugin.Id(),
+			app: a,
+		}
+
+		err := plugin.Shutdown(&ctx)
+		if err != nil {
+			a.log.Error("Plugin shutdown failed", "name", plugin.Name(), "error", err)
+
+			errs = append(errs, fmt.Errorf("%s: %w", plugin.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (a *App) AddCheck(namespace string, check Check) {
+	if check.Run == nil && check.RunContext == nil {
+		a.log.Warn("Check has neither Run nor RunContext. Skipping", "name", check.Key, "namespace", namespace)
+
+		return
+	}
+
 	checkExists, _ := a.GetCheck(check.Key)
 	if checkExists != nil {
 		a.log.Warn("Check already exists. Skippting", "name", check.Key, "namespace", namespace)
@@ -91,6 +137,50 @@ func (a *App) AddCheck(namespace string, check Check) {
 	a.checks[namespace] = namespaceVals
 }
 
+// AddCheckFactory registers a check whose instances are built per job. The
+// factory is called once here to learn the check key and name.
+func (a *App) AddCheckFactory(namespace string, factory CheckFactory) {
+	check := factory()
+
+	checkExists, _ := a.GetCheck(check.Key)
+	if checkExists != nil {
+		a.log.Warn("Check already exists. Skippting", "name", check.Key, "namespace", namespace)
+
+		return
+	}
+
+	a.AddCheck(namespace, check)
+
+	if _, ok := a.checks[namespace][check.Key]; ok {
+		a.factories[check.Key] = factory
+	}
+}
+
+// AddNotifier registers a notifier kind. Instances of it are created from the
+// notifiers section of the config.
+func (a *App) AddNotifier(namespace string, kind string, factory NotifierFactory) {
+	if _, ok := a.notifiers[kind]; ok {
+		a.log.Warn("Notifier already exists. Skipping", "kind", kind, "namespace", namespace)
+
+		return
+	}
+
+	a.notifiers[kind] = factory
+}
+
+// NewCheck returns the check to use for a new job. Checks registered with a
+// factory get a fresh instance, others are shared between jobs.
+func (a *App) NewCheck(key string) (*Check, error) {
+	factory, ok := a.factories[key]
+	if !ok {
+		return a.GetCheck(key)
+	}
+
+	check := factory()
+
+	return &check, nil
+}
+
 func (a *App) GetCheck(key string) (*Check, error) {
 	for _, namespace := range a.checks {
 		if check, ok := namespace[key]; ok {
@@ -101,26 +191,133 @@ func (a *App) GetCheck(key string) (*Check, error) {
 	return nil, fmt.Errorf("check not found")
 }
 
+// ValidateConfig checks that every configured check exists and accepts its
+// arguments.
+func (a *App) ValidateConfig(config Config) error {
+	keys := map[string]bool{}
+
+	for _, domain := range config.Domains {
+		for _, checkConfig := range domain.Checks {
+			if keys[jobKey(domain, checkConfig)] {
+				return fmt.Errorf("domain %s: check %s is configured twice", domain.Domain, checkConfig.Key)
+			}
+
+			keys[jobKey(domain, checkConfig)] = true
+
+			check, _ := a.GetCheck(checkConfig.Key)
+			if check == nil && a.loadErrors > 0 {
+				return fmt.Errorf("domain %s: check %s not found, plugin load errors: %d", domain.Domain, checkConfig.Key, a.loadErrors)
+			}
+
+			if check == nil {
+				return fmt.Errorf("domain %s: check %s not found", domain.Domain, checkConfig.Key)
+			}
+
+			if checkConfig.Schedule != "" {
+				if checkConfig.Interval > 0 {
+					return fmt.Errorf("domain %s: check %s can't have both schedule and interval", domain.Domain, checkConfig.Key)
+				}
+
+				schedule, err := ParseCron(checkConfig.Schedule)
+				if err != nil {
+					return fmt.Errorf("domain %s: check %s: invalid schedule: %w", domain.Domain, checkConfig.Key, err)
+				}
+
+				if schedule.Next(time.Now()).IsZero() {
+					return fmt.Errorf("domain %s: check %s: schedule never fires", domain.Domain, checkConfig.Key)
+				}
+			}
+
+			if checkConfig.FailureBackoff != 0 && checkConfig.FailureBackoff < 1 {
+				return fmt.Errorf("domain %s: check %s: failure_backoff must be at least 1", domain.Domain, checkConfig.Key)
+			}
+
+			if checkConfig.FailureIntervalMax > 0 && checkConfig.FailureInterval <= 0 {
+				return fmt.Errorf("domain %s: check %s: failure_interval_max requires failure_interval", domain.Domain, checkConfig.Key)
+			}
+
+			if checkConfig.FlapThresholdLow > checkConfig.FlapThresholdHigh {
+				return fmt.Errorf("domain %s: check %s: flap_threshold_low must not exceed flap_threshold_high", domain.Domain, checkConfig.Key)
+			}
+
+			if check.ValidateArgs != nil {
+				err := check.ValidateArgs(checkConfig.Args)
+				if err != nil {
+					return fmt.Errorf("domain %s: check %s: %w", domain.Domain, checkConfig.Key, err)
+				}
+			}
+		}
+	}
+
+	_, err := resolveDependencies(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = compileMaintenance(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = compileSLA(config.SLA)
+	if err != nil {
+		return err
+	}
+
+	err = a.validateNotifiers(config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BuildJobs creates a job for every configured check, starting at now.
+func (a *App) BuildJobs(config Config, now time.Time) ([]*Job, error) {
+	dependencies, err := resolveDependencies(config)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []*Job{}
+	for _, domain := range config.Domains {
+		for _, checkConfig := range domain.Checks {
+			check, err := a.NewCheck(checkConfig.Key)
+			if err != nil {
+				return nil, fmt.Errorf("domain %s: check %s: %w", domain.Domain, checkConfig.Key, err)
+			}
+
+			job, err := NewJob(domain, *check, checkConfig)
+			if err != nil {
+				return nil, fmt.Errorf("domain %s: check %s: %w", domain.Domain, checkConfig.Key, err)
+			}
+
+			job.dependsOn = dependencies[job.Key()]
+			job.Start(now, config.Splay)
+
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	log := slog.New(tint.NewHandler(os.Stdout, &tint.Options{
 		AddSource:  true,
 		TimeFormat: time.DateTime,
 		Level:      slog.LevelDebug,
 	}))
 
-	configData, err := os.ReadFile("config.yaml")
-	if err != nil {
-		log.Error("Failed to read config file", "error", err)
-
-		os.Exit(1)
-	}
-
 	log.Info("Loading config...")
 
-	config := Config{}
-	err = yaml.Unmarshal(configData, &config)
+	config, err := LoadConfig(configPath)
 	if err != nil {
-		log.Error("Failed to unmarshal config", "error", err)
+		log.Error("Failed to load config", "error", err)
 
 		os.Exit(1)
 	}
@@ -131,13 +328,21 @@ func main() {
 
 	log.Info("Loading plugins...")
 
-	plugins, err := LoadDynamicPluginsFromDir("./plugins")
+	plugins, loadErrs, err := LoadDynamicPluginsFromDir("./plugins")
 	if err != nil {
 		log.Error("Failed to load plugins", "error", err)
 
 		os.Exit(1)
 	}
 
+	// A broken plugin doesn't stop the others. Checks it would have added
+	// fail the config validation if they are used.
+	for _, err := range loadErrs {
+		log.Error("Failed to load plugin", "error", err)
+	}
+
+	app.loadErrors = len(loadErrs)
+
 	for _, plugin := range plugins {
 		err := app.AddPlugin(plugin)
 		if err != nil {
@@ -149,118 +354,156 @@ func main() {
 
 	log.Info("Validate config...")
 
-	for _, domain := range config.Domains {
-		for _, checkConfig := range domain.Checks {
-			check, _ := app.GetCheck(checkConfig.Key)
-			if check == nil {
-				log.Error("Check not found", "name", checkConfig.Key, "domain", domain.Domain)
+	err = app.ValidateConfig(config)
+	if err != nil {
+		log.Error("Config validation failed", "error", err)
 
-				os.Exit(1)
-			}
+		os.Exit(1)
+	}
 
-			if check.ValidateArgs != nil {
-				err := check.ValidateArgs(checkConfig.Args)
-				if err != nil {
-					log.Error("Check args validation failed", "name", check.Name, "domain", domain.Domain, "error", err)
+	log.Info("Config validated", "plugin_load_errors", app.loadErrors)
 
-					os.Exit(1)
-				}
-			}
-		}
+	jobs, err := app.BuildJobs(config, time.Now())
+	if err != nil {
+		log.Error("Failed to create jobs", "error", err)
+
+		os.Exit(1)
 	}
 
-	log.Info("Config validated")
+	for _, job := range jobs {
+		log.Info("Adding job", "name", job.check.Name, "domain", job.domain.Domain, "args", job.checkConfig.Args)
+	}
 
-	jobs := []*Job{}
-	for _, domain := range config.Domains {
-		for _, checkConfig := range domain.Checks {
-			check, err := app.GetCheck(checkConfig.Key)
-			if err != nil {
-				log.Error("Check not found", "name", checkConfig.Key, "domain", domain.Domain)
+	notifiers, err := app.BuildNotifiers(config)
+	if err != nil {
+		log.Error("Failed to create notifiers", "error", err)
 
-				os.Exit(1)
-			}
+		os.Exit(1)
+	}
 
-			log.Info("Adding job", "name", check.Name, "domain", domain.Domain, "args", checkConfig.Args)
+	dispatcher, err := NewDispatcher(log, config, notifiers)
+	if err != nil {
+		log.Error("Failed to create dispatcher", "error", err)
 
-			jobs = append(jobs, &Job{
-				domain:      domain,
-				check:       *check,
-				checkConfig: checkConfig,
-				next:        time.Now(),
-			})
-		}
+		os.Exit(1)
 	}
 
-	log.Info("Starting scheduler...")
+	go dispatcher.Run()
+
+	dataDir := config.DataDir
+	if dataDir == "" {
+		dataDir = defaultDataDir
+	}
 
-	exit := make(chan os.Signal, 1)
-	signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
+	err = os.MkdirAll(dataDir, 0o755)
+	if err != nil {
+		log.Error("Failed to create data directory", "path", dataDir, "error", err)
 
-	ticker := time.NewTicker(time.Second)
+		os.Exit(1)
+	}
 
-mainloop:
-	for {
-		select {
-		case <-exit:
-			log.Info("Exiting...")
+	incidents, err := NewIncidentStore(log, dataDir, resultRetention(config.Results))
+	if err != nil {
+		log.Error("Failed to load incidents", "error", err)
 
-			break mainloop
-		case <-ticker.C:
-			now := time.Now()
+		os.Exit(1)
+	}
 
-			for _, job := range jobs {
-				if !job.next.Before(now) {
-					continue
-				}
+	incidents.CloseMissing(jobs)
 
-				log.Info("Running check", "name", job.check.Name, "domain", job.domain.Domain)
+	results, err := NewResultStore(log, config.Results, dataDir)
+	if err != nil {
+		log.Error("Failed to open result store", "error", err)
 
-				result := job.check.Run(job.domain.Domain, job.checkConfig.Args)
+		os.Exit(1)
+	}
 
-				if !result.Success {
-					if result.Severity == SeverityDebug {
-						log.Debug("Check Debug", "name", job.check.Name, "domain", job.domain.Domain, "message", result.Message)
-					}
+	log.Info("Starting scheduler...")
 
-					if result.Severity == SeverityNotice {
-						log.Info("Check Notice", "name", job.check.Name, "domain", job.domain.Domain, "message", result.Message)
-					}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-					if result.Severity == SeverityWarning {
-						log.Warn("Check Warning", "name", job.check.Name, "domain", job.domain.Domain, "message", result.Message)
+	scheduler, err := NewScheduler(log, config, jobs, dispatcher, incidents, results)
+	if err != nil {
+		log.Error("Failed to create scheduler", "error", err)
 
-					}
+		os.Exit(1)
+	}
 
-					if result.Severity == SeverityError {
-						log.Error("Check Error", "name", job.check.Name, "domain", job.domain.Domain, "message", result.Message)
-					}
+	control := NewControlServer(log, app, scheduler, dispatcher, incidents, results, config.ControlSocket)
 
-					if result.Severity == SeverityDown {
-						log.Error("Domain Down", "name", job.check.Name, "domain", job.domain.Domain, "message", result.Message)
-					}
+	err = control.Listen()
+	if err != nil {
+		log.Error("Failed to open control socket", "error", err)
 
-					if result.Severity == SeverityFatal {
-						log.Error("Check Fatal", "name", job.check.Name, "domain", job.domain.Domain, "message", result.Message)
+		os.Exit(1)
+	}
 
-						break mainloop
-					}
-				}
+	go control.Serve()
 
-				if job.checkConfig.Interval > 0 {
-					job.next = now.Add(job.checkConfig.Interval)
+	var metrics *MetricsServer
+	if config.MetricsListen != "" {
+		metrics = NewMetricsServer(log, app, scheduler, config.MetricsListen)
 
-					continue
-				}
+		err = metrics.Listen()
+		if err != nil {
+			log.Error("Failed to open metrics listener", "error", err)
 
-				if job.domain.Interval > 0 {
-					job.next = now.Add(job.domain.Interval)
+			os.Exit(1)
+		}
 
-					continue
-				}
+		go metrics.Serve()
+	}
 
-				job.next = now.Add(time.Minute)
-			}
+	var status *StatusServer
+	if config.StatusListen != "" {
+		status = NewStatusServer(log, control.ReadOnlyHandler(), config.StatusListen)
+
+		err = status.Listen()
+		if err != nil {
+			log.Error("Failed to open status listener", "error", err)
+
+			os.Exit(1)
 		}
+
+		go status.Serve()
+	}
+
+	reloader := NewReloader(log, app, scheduler, dispatcher, configPath, config.WatchConfig)
+	go reloader.Run(ctx)
+
+	runErr := scheduler.Run(ctx)
+
+	control.Close()
+	dispatcher.Close()
+
+	if metrics != nil {
+		metrics.Close()
+	}
+
+	if status != nil {
+		status.Close()
+	}
+
+	incidents.Save()
+
+	err = results.Close()
+	if err != nil {
+		log.Error("Failed to close result store", "error", err)
 	}
+
+	log.Info("Shutting down...")
+
+	err = app.Shutdown()
+	if err != nil {
+		log.Error("Shutdown failed", "error", err)
+
+		os.Exit(1)
+	}
+
+	if runErr != nil {
+		os.Exit(1)
+	}
+
+	log.Info("Shutdown complete")
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// factoryPlugin registers a check through a factory. Every instance counts
+// its own runs.
+const factoryPlugin = `package main
+
+import (
+	"context"
+	"fmt"
+
+	uptimegopher "uptime-gopher/uptime-gopher"
+)
+
+var Name = "Factory Plugin"
+
+func Setup(ctx *uptimegopher.PluginCtx) error {
+	ctx.AddCheckFactory(func() uptimegopher.Check {
+		runs := 0
+
+		return uptimegopher.Check{
+			Key:  "counter",
+			Name: "Counter Check",
+			RunContext: func(ctx context.Context, address string, args map[string]string) uptimegopher.CheckResult {
+				runs++
+
+				return uptimegopher.CheckResult{Success: true, Message: fmt.Sprintf("%s run %d", address, runs)}
+			},
+		}
+	})
+
+	return nil
+}
+
+func Shutdown(ctx *uptimegopher.PluginCtx) error {
+	return nil
+}
+`
+
+func TestCheckFactoryBuildsInstancePerJob(t *testing.T) {
+	plugin, err := NewDynamicPlugin(fstest.MapFS{
+		"plugin.go": {Data: []byte(factoryPlugin)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err = app.AddPlugin(plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := app.factories["counter"]; !ok {
+		t.Fatal("factory of an interpreted plugin not registered")
+	}
+
+	config := Config{
+		Domains: []Domain{
+			{Domain: "a.example.com", Checks: []CheckConfig{{Key: "counter"}}},
+			{Domain: "b.example.com", Checks: []CheckConfig{{Key: "counter"}}},
+		},
+	}
+
+	jobs, err := app.BuildJobs(config, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(job *Job) string {
+		return job.check.run(context.Background(), job.domain.Domain, job.checkConfig.Args).Message
+	}
+
+	run(jobs[0])
+	run(jobs[0])
+
+	if got := run(jobs[0]); got != "a.example.com run 3" {
+		t.Fatalf("got %q from the first job", got)
+	}
+
+	// The second job has its own instance, so the runs of the first one
+	// don't show up in its count.
+	if got := run(jobs[1]); got != "b.example.com run 1" {
+		t.Fatalf("got %q from the second job", got)
+	}
+
+	// The instance that registered the check is not shared with any job.
+	check, err := app.GetCheck("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := check.run(context.Background(), "registered", nil).Message; got != "registered run 1" {
+		t.Fatalf("got %q from the registered instance", got)
+	}
+}
+
+func TestValidateConfigReportsPluginLoadErrors(t *testing.T) {
+	app := NewApp(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	config := Config{
+		Domains: []Domain{{Domain: "example.com", Checks: []CheckConfig{{Key: "counter"}}}},
+	}
+
+	err := app.ValidateConfig(config)
+	if err == nil || err.Error() != "domain example.com: check counter not found" {
+		t.Fatalf("got %v", err)
+	}
+
+	app.loadErrors = 1
+
+	err = app.ValidateConfig(config)
+	if err == nil || err.Error() != "domain example.com: check counter not found, plugin load errors: 1" {
+		t.Fatalf("got %v", err)
+	}
+}
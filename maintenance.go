@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	MaintenanceMute  = "mute"
+	MaintenancePause = "pause"
+)
+
+// Maintenance is a compiled maintenance window.
+type Maintenance struct {
+	MaintenanceWindow
+
+	// domain is set for windows declared on a domain.
+	domain *Domain
+
+	start    time.Time
+	end      time.Time
+	schedule *CronSchedule
+}
+
+func NewMaintenance(window MaintenanceWindow, domain *Domain) (*Maintenance, error) {
+	// Jobs refer to the window they are in by name, so an unnamed window
+	// would neither mute nor exclude anything.
+	if window.Name == "" {
+		return nil, fmt.Errorf("maintenance window needs a name")
+	}
+
+	if window.Mode == "" {
+		window.Mode = MaintenanceMute
+	}
+
+	if window.Mode != MaintenanceMute && window.Mode != MaintenancePause {
+		return nil, fmt.Errorf("maintenance %s: mode must be %s or %s", window.Name, MaintenanceMute, MaintenancePause)
+	}
+
+	loc := time.Local
+	if window.Timezone != "" {
+		var err error
+
+		loc, err = time.LoadLocation(window.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance %s: invalid timezone: %w", window.Name, err)
+		}
+	}
+
+	m := &Maintenance{
+		MaintenanceWindow: window,
+		domain:            domain,
+	}
+
+	if window.Schedule != "" {
+		if window.Duration <= 0 {
+			return nil, fmt.Errorf("maintenance %s: schedule requires a duration", window.Name)
+		}
+
+		expr := window.Schedule
+		if window.Timezone != "" {
+			expr = "CRON_TZ=" + window.Timezone + " " + expr
+		}
+
+		schedule, err := ParseCron(expr)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance %s: invalid schedule: %w", window.Name, err)
+		}
+
+		m.schedule = schedule
+
+		return m, nil
+	}
+
+	start, err := parseMaintenanceTime(window.Start, loc)
+	if err != nil {
+		return nil, fmt.Errorf("maintenance %s: invalid start: %w", window.Name, err)
+	}
+
+	end := start.Add(window.Duration)
+	if window.End != "" {
+		end, err = parseMaintenanceTime(window.End, loc)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance %s: invalid end: %w", window.Name, err)
+		}
+	}
+
+	if !end.After(start) {
+		return nil, fmt.Errorf("maintenance %s: needs an end after its start, or a duration", window.Name)
+	}
+
+	m.start = start
+	m.end = end
+
+	return m, nil
+}
+
+func parseMaintenanceTime(value string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation("2006-01-02 15:04", value, loc)
+}
+
+// Active reports whether the window is open at now.
+func (m *Maintenance) Active(now time.Time) bool {
+	if m.schedule != nil {
+		// The window is open if an occurrence started within the last
+		// Duration.
+		start := m.schedule.Next(now.Add(-m.Duration))
+
+		return !start.IsZero() && !start.After(now)
+	}
+
+	return !now.Before(m.start) && now.Before(m.end)
+}
+
+// Expired reports whether a one-off window has ended for good.
+func (m *Maintenance) Expired(now time.Time) bool {
+	return m.schedule == nil && !now.Before(m.end)
+}
+
+func (m *Maintenance) Matches(job *Job) bool {
+	if m.domain != nil {
+		if m.domain.Domain != job.domain.Domain {
+			return false
+		}
+	} else if len(m.Domains) > 0 && !slices.Contains(m.Domains, job.domain.Domain) && (job.domain.Key == "" || !slices.Contains(m.Domains, job.domain.Key)) {
+		return false
+	}
+
+	return len(m.Checks) == 0 || slices.Contains(m.Checks, job.checkConfig.Key)
+}
+
+// compileMaintenance compiles the global and per-domain windows of config.
+// Window names must be unique across both.
+func compileMaintenance(config Config) ([]*Maintenance, error) {
+	windows := []*Maintenance{}
+	names := map[string]bool{}
+
+	for _, window := range config.Maintenance {
+		m, err := NewMaintenance(window, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if names[m.Name] {
+			return nil, fmt.Errorf("maintenance %s is configured twice", m.Name)
+		}
+
+		names[m.Name] = true
+		windows = append(windows, m)
+	}
+
+	for i := range config.Domains {
+		domain := &config.Domains[i]
+
+		for _, window := range domain.Maintenance {
+			m, err := NewMaintenance(window, domain)
+			if err != nil {
+				return nil, fmt.Errorf("domain %s: %w", domain.Domain, err)
+			}
+
+			if names[m.Name] {
+				return nil, fmt.Errorf("domain %s: maintenance %s is configured twice", domain.Domain, m.Name)
+			}
+
+			names[m.Name] = true
+			windows = append(windows, m)
+		}
+	}
+
+	return windows, nil
+}
+
+// MaintenanceStatus describes a maintenance window for the control API.
+type MaintenanceStatus struct {
+	MaintenanceWindow
+
+	// Domain is the address of the domain a domain window belongs to.
+	Domain  string `json:"domain,omitempty"`
+	Runtime bool   `json:"runtime"`
+	Active  bool   `json:"active"`
+}
+
+// MaintenanceWindows lists the configured and runtime windows. Runtime
+// windows that have ended are dropped.
+func (s *Scheduler) MaintenanceWindows(now time.Time) ([]MaintenanceStatus, bool) {
+	statuses := []MaintenanceStatus{}
+
+	ok := s.Do(func() {
+		s.runtimeWindows = slices.DeleteFunc(s.runtimeWindows, func(m *Maintenance) bool {
+			return m.Expired(now)
+		})
+
+		for _, m := range s.maintenance {
+			statuses = append(statuses, m.status(now, false))
+		}
+
+		for _, m := range s.runtimeWindows {
+			statuses = append(statuses, m.status(now, true))
+		}
+	})
+
+	return statuses, ok
+}
+
+// AddMaintenance adds a window at runtime. Runtime windows are not written to
+// the config and are lost on restart.
+func (s *Scheduler) AddMaintenance(window MaintenanceWindow) error {
+	m, err := NewMaintenance(window, nil)
+	if err != nil {
+		return err
+	}
+
+	if m.Expired(time.Now()) {
+		return fmt.Errorf("maintenance %s: already ended", window.Name)
+	}
+
+	ok := s.Do(func() {
+		for _, existing := range slices.Concat(s.maintenance, s.runtimeWindows) {
+			if existing.Name == window.Name {
+				err = fmt.Errorf("maintenance %s already exists", window.Name)
+
+				return
+			}
+		}
+
+		s.runtimeWindows = append(s.runtimeWindows, m)
+
+		s.log.Info("Maintenance window added", "maintenance", window.Name, "mode", m.Mode)
+	})
+	if !ok {
+		return errNotRunning
+	}
+
+	return err
+}
+
+// RemoveMaintenance removes a window added at runtime. Configured windows
+// can only be removed from the config.
+func (s *Scheduler) RemoveMaintenance(name string) error {
+	var err error
+
+	ok := s.Do(func() {
+		i := slices.IndexFunc(s.runtimeWindows, func(m *Maintenance) bool {
+			return m.Name == name
+		})
+		if i < 0 {
+			err = fmt.Errorf("maintenance %s: %w", name, errNotFound)
+
+			return
+		}
+
+		s.runtimeWindows = slices.Delete(s.runtimeWindows, i, i+1)
+
+		s.log.Info("Maintenance window removed", "maintenance", name)
+	})
+	if !ok {
+		return errNotRunning
+	}
+
+	return err
+}
+
+func (m *Maintenance) status(now time.Time, runtime bool) MaintenanceStatus {
+	status := MaintenanceStatus{
+		MaintenanceWindow: m.MaintenanceWindow,
+		Runtime:           runtime,
+		Active:            m.Active(now),
+	}
+
+	if m.domain != nil {
+		status.Domain = m.domain.Domain
+	}
+
+	return status
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileMaintenanceNames(t *testing.T) {
+	window := MaintenanceWindow{Name: "deploy", Start: "2024-06-01 02:00", Duration: time.Hour}
+	unnamed := MaintenanceWindow{Start: "2024-06-01 02:00", Duration: time.Hour}
+
+	tests := []struct {
+		name   string
+		config Config
+		fails  bool
+	}{
+		{
+			name:   "unique names",
+			config: Config{Maintenance: []MaintenanceWindow{window}, Domains: []Domain{{Domain: "example.com", Maintenance: []MaintenanceWindow{{Name: "migration", Start: window.Start, Duration: time.Hour}}}}},
+		},
+		{
+			name:   "unnamed window",
+			config: Config{Maintenance: []MaintenanceWindow{unnamed}},
+			fails:  true,
+		},
+		{
+			name:   "unnamed domain window",
+			config: Config{Domains: []Domain{{Domain: "example.com", Maintenance: []MaintenanceWindow{unnamed}}}},
+			fails:  true,
+		},
+		{
+			name:   "duplicate name",
+			config: Config{Maintenance: []MaintenanceWindow{window, window}},
+			fails:  true,
+		},
+		{
+			name:   "duplicate name on a domain",
+			config: Config{Maintenance: []MaintenanceWindow{window}, Domains: []Domain{{Domain: "example.com", Maintenance: []MaintenanceWindow{window}}}},
+			fails:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := compileMaintenance(test.config)
+			if test.fails != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, test.fails)
+			}
+		})
+	}
+}
+
+func TestMaintenanceActive(t *testing.T) {
+	oneOff, err := NewMaintenance(MaintenanceWindow{Name: "migration", Start: "2024-06-01 02:00", End: "2024-06-01 04:00", Timezone: "UTC"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-06-04 is a Tuesday.
+	weekly, err := NewMaintenance(MaintenanceWindow{Name: "deploy", Schedule: "0 22 * * tue", Duration: 30 * time.Minute, Timezone: "UTC"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		window  *Maintenance
+		at      string
+		active  bool
+		expired bool
+	}{
+		{oneOff, "2024-06-01T01:59:59Z", false, false},
+		{oneOff, "2024-06-01T02:00:00Z", true, false},
+		{oneOff, "2024-06-01T03:59:59Z", true, false},
+		{oneOff, "2024-06-01T04:00:00Z", false, true},
+		{weekly, "2024-06-04T21:59:59Z", false, false},
+		{weekly, "2024-06-04T22:00:00Z", true, false},
+		{weekly, "2024-06-04T22:29:59Z", true, false},
+		{weekly, "2024-06-04T22:30:00Z", false, false},
+		{weekly, "2024-06-11T22:15:00Z", true, false},
+	}
+
+	for _, test := range tests {
+		at, err := time.Parse(time.RFC3339, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := test.window.Active(at); got != test.active {
+			t.Errorf("%s at %s: got active %v, want %v", test.window.Name, test.at, got, test.active)
+		}
+
+		if got := test.window.Expired(at); got != test.expired {
+			t.Errorf("%s at %s: got expired %v, want %v", test.window.Name, test.at, got, test.expired)
+		}
+	}
+}
+
+func TestSchedulerMaintenanceMutes(t *testing.T) {
+	now := time.Now()
+
+	config := Config{
+		Maintenance: []MaintenanceWindow{{
+			Name:    "deploy",
+			Start:   now.Add(-time.Hour).Format(time.RFC3339),
+			End:     now.Add(time.Hour).Format(time.RFC3339),
+			Domains: []string{"example.com"},
+		}},
+	}
+
+	muted, err := NewJob(Domain{Domain: "example.com"}, Check{Name: "Test"}, CheckConfig{Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewJob(Domain{Domain: "example.org"}, Check{Name: "Test"}, CheckConfig{Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memoryStore{}
+	s := testScheduler(t, config, store, muted, other)
+
+	for _, job := range []*Job{muted, other} {
+		if s.pause(job, now) {
+			t.Fatalf("%s paused by a mute window", job.Key())
+		}
+
+		s.handleResult(jobResult{
+			job:     job,
+			result:  CheckResult{Success: false, Severity: SeverityDown},
+			started: now,
+		})
+
+		if job.State() != StateDown {
+			t.Fatalf("%s is %s, want DOWN", job.Key(), job.State())
+		}
+	}
+
+	notified := []string{}
+	for _, event := range queuedEvents(s) {
+		if !event.muted {
+			notified = append(notified, event.Job)
+		}
+	}
+
+	if len(notified) != 1 || notified[0] != other.Key() {
+		t.Fatalf("got events for %v, want only %s", notified, other.Key())
+	}
+
+	if store.records[0].Maintenance != "deploy" || store.records[1].Maintenance != "" {
+		t.Fatalf("got results in maintenance %q and %q", store.records[0].Maintenance, store.records[1].Maintenance)
+	}
+
+	s.maintenance[0].Mode = MaintenancePause
+
+	if !s.pause(muted, now) || s.pause(other, now) {
+		t.Fatal("pause window did not pause only the matching job")
+	}
+
+	if s.pause(muted, now.Add(time.Hour)) || muted.maintenance != "" {
+		t.Fatal("job still in maintenance after the window ended")
+	}
+}
+
+func TestSLAExcludesMaintenance(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	job, err := NewJob(Domain{Domain: "example.com"}, Check{}, CheckConfig{Key: "http", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memoryStore{}
+	for at := now.Add(-24 * time.Hour); at.Before(now); at = at.Add(time.Hour) {
+		record := ResultRecord{Job: job.Key(), At: at, Success: true}
+
+		// One hour of downtime during a window.
+		if at.Equal(now.Add(-12 * time.Hour)) {
+			record.Success = false
+			record.Severity = SeverityDown
+			record.Maintenance = "deploy"
+		}
+
+		store.records = append(store.records, record)
+	}
+
+	for _, include := range []bool{false, true} {
+		sla, err := compileSLA(SLAConfig{Windows: []string{"24h"}, IncludeMaintenance: include})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		domains, err := sla.Compute(store, []*Job{job}, nil, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		day := domains[0].Jobs[0].Windows[0]
+
+		want, excluded := 100.0, 3600.0
+		if include {
+			want, excluded = 100*23.0/24, 0
+		}
+
+		if day.Percent == nil || *day.Percent != want || day.ExcludedSeconds != excluded {
+			t.Fatalf("include %v: got %v%% with %vs excluded, want %v%% with %vs", include, day.Percent, day.ExcludedSeconds, want, excluded)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var jobStates = []State{StateUnknown, StateUp, StateDegraded, StateDown, StateFlapping}
+
+// MetricsServer serves /metrics in the Prometheus text format on its own
+// listener.
+type MetricsServer struct {
+	log *slog.Logger
+
+	app       *App
+	scheduler *Scheduler
+
+	addr     string
+	listener net.Listener
+	server   *http.Server
+}
+
+func NewMetricsServer(log *slog.Logger, app *App, scheduler *Scheduler, addr string) *MetricsServer {
+	log = log.With("service", "Metrics")
+
+	metrics := &MetricsServer{
+		log: log,
+
+		app:       app,
+		scheduler: scheduler,
+
+		addr: addr,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", metrics.serveMetrics)
+
+	metrics.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return metrics
+}
+
+func (m *MetricsServer) Listen() error {
+	listener, err := net.Listen("tcp", m.addr)
+	if err != nil {
+		return err
+	}
+
+	m.listener = listener
+
+	m.log.Info("Metrics listening", "addr", listener.Addr())
+
+	return nil
+}
+
+func (m *MetricsServer) Serve() {
+	err := m.server.Serve(m.listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		m.log.Error("Metrics server failed", "error", err)
+	}
+}
+
+func (m *MetricsServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.server.Shutdown(ctx)
+	if err != nil {
+		m.log.Warn("Metrics server shutdown failed", "error", err)
+	}
+}
+
+func (m *MetricsServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	status, ok := m.scheduler.Status(time.Now())
+	if !ok {
+		http.Error(w, errNotRunning.Error(), http.StatusServiceUnavailable)
+
+		return
+	}
+
+	out := &metricWriter{}
+
+	out.family("uptime_gopher_job_up", "gauge", "Whether the job is UP.")
+	for _, job := range status.Jobs {
+		out.sample("uptime_gopher_job_up", jobLabels(job), boolValue(job.State == StateUp.String()))
+	}
+
+	out.family("uptime_gopher_job_state", "gauge", "The state of the job, 1 for the current state and 0 for the others.")
+	for _, job := range status.Jobs {
+		for _, state := range jobStates {
+			out.sample("uptime_gopher_job_state", append(jobLabels(job), "state", state.String()), boolValue(job.State == state.String()))
+		}
+	}
+
+	out.family("uptime_gopher_job_last_run_timestamp_seconds", "gauge", "When the last check of the job started.")
+	for _, job := range status.Jobs {
+		if job.LastResult != nil {
+			out.sample("uptime_gopher_job_last_run_timestamp_seconds", jobLabels(job), float64(job.LastResult.At.UnixMilli())/1000)
+		}
+	}
+
+	out.family("uptime_gopher_job_last_duration_seconds", "gauge", "How long the last check of the job took.")
+	for _, job := range status.Jobs {
+		if job.LastResult != nil {
+			out.sample("uptime_gopher_job_last_duration_seconds", jobLabels(job), job.LastResult.Duration.Seconds())
+		}
+	}
+
+	out.family("uptime_gopher_job_last_success", "gauge", "Whether the last check of the job succeeded.")
+	for _, job := range status.Jobs {
+		if job.LastResult != nil {
+			out.sample("uptime_gopher_job_last_success", jobLabels(job), boolValue(job.LastResult.Success))
+		}
+	}
+
+	out.family("uptime_gopher_job_severity", "gauge", "Severity of the last result: 0 debug, 1 notice, 2 warning, 3 error, 4 down, 5 fatal.")
+	for _, job := range status.Jobs {
+		if job.LastResult != nil {
+			out.sample("uptime_gopher_job_severity", jobLabels(job), float64(job.LastResult.Severity))
+		}
+	}
+
+	out.family("uptime_gopher_job_consecutive_failures", "gauge", "Number of failed checks of the job in a row.")
+	for _, job := range status.Jobs {
+		out.sample("uptime_gopher_job_consecutive_failures", jobLabels(job), float64(job.ConsecutiveFailures))
+	}
+
+	out.family("uptime_gopher_job_quarantined", "gauge", "Whether the job is quarantined after a fatal result.")
+	for _, job := range status.Jobs {
+		out.sample("uptime_gopher_job_quarantined", jobLabels(job), boolValue(job.Quarantined))
+	}
+
+	out.family("uptime_gopher_job_maintenance", "gauge", "Whether the job is in a maintenance window.")
+	for _, job := range status.Jobs {
+		out.sample("uptime_gopher_job_maintenance", jobLabels(job), boolValue(job.Maintenance != ""))
+	}
+
+	out.family("uptime_gopher_check_metric", "gauge", "Metrics reported by the last check of the job.")
+	for _, job := range status.Jobs {
+		if job.LastResult == nil {
+			continue
+		}
+
+		names := make([]string, 0, len(job.LastResult.Metrics))
+		for name := range job.LastResult.Metrics {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		for _, name := range names {
+			out.sample("uptime_gopher_check_metric", append(jobLabels(job), "metric", name), job.LastResult.Metrics[name])
+		}
+	}
+
+	out.family("uptime_gopher_scheduler_jobs", "gauge", "Number of scheduled jobs.")
+	out.sample("uptime_gopher_scheduler_jobs", nil, float64(len(status.Jobs)))
+
+	out.family("uptime_gopher_scheduler_running_checks", "gauge", "Number of checks running right now.")
+	out.sample("uptime_gopher_scheduler_running_checks", nil, float64(status.Running))
+
+	out.family("uptime_gopher_scheduler_waiting_checks", "gauge", "Number of due checks waiting for a free worker.")
+	out.sample("uptime_gopher_scheduler_waiting_checks", nil, float64(status.Waiting))
+
+	out.family("uptime_gopher_scheduler_queue_lag_seconds", "gauge", "How long the oldest waiting check is overdue.")
+	out.sample("uptime_gopher_scheduler_queue_lag_seconds", nil, status.QueueLag.Seconds())
+
+	out.family("uptime_gopher_plugins_loaded", "gauge", "Number of loaded plugins.")
+	out.sample("uptime_gopher_plugins_loaded", nil, float64(len(m.app.plugins)))
+
+	out.family("uptime_gopher_plugin_load_errors", "gauge", "Number of plugins that failed to load on startup.")
+	out.sample("uptime_gopher_plugin_load_errors", nil, float64(m.app.loadErrors))
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(out.buf.Bytes())
+}
+
+func jobLabels(job JobStatus) []string {
+	return []string{"domain", job.Domain, "check", job.Check}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+// metricWriter encodes metrics in the Prometheus text exposition format.
+// All samples of a family must follow its family line.
+type metricWriter struct {
+	buf bytes.Buffer
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func (m *metricWriter) family(name, kind, help string) {
+	m.buf.WriteString("# HELP " + name + " " + helpEscaper.Replace(help) + "\n")
+	m.buf.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes one sample. labels holds label names and values in turns.
+func (m *metricWriter) sample(name string, labels []string, value float64) {
+	m.buf.WriteString(name)
+
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+
+			m.buf.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+		}
+
+		m.buf.WriteByte('}')
+	}
+
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(formatValue(value))
+	m.buf.WriteByte('\n')
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
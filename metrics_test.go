@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricWriter(t *testing.T) {
+	out := &metricWriter{}
+
+	out.family("test_up", "gauge", "Whether it is up.\nSecond line with a \\ backslash.")
+	out.sample("test_up", []string{"domain", "example.com", "check", "http"}, 1)
+	out.sample("test_up", []string{"domain", `quote " and \ and` + "\nnewline", "check", ""}, 0)
+
+	out.family("test_values", "counter", "Special values.")
+	out.sample("test_values", nil, 0.25)
+	out.sample("test_values", nil, 1e21)
+	out.sample("test_values", nil, -3)
+	out.sample("test_values", nil, math.NaN())
+	out.sample("test_values", nil, math.Inf(1))
+	out.sample("test_values", nil, math.Inf(-1))
+
+	want := `# HELP test_up Whether it is up.\nSecond line with a \\ backslash.
+# TYPE test_up gauge
+test_up{domain="example.com",check="http"} 1
+test_up{domain="quote \" and \\ and\nnewline",check=""} 0
+# HELP test_values Special values.
+# TYPE test_values counter
+test_values 0.25
+test_values 1e+21
+test_values -3
+test_values NaN
+test_values +Inf
+test_values -Inf
+`
+
+	if got := out.buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	job := testJob(t, time.Minute)
+	job.lastRun = time.Unix(1700000000, 0)
+	job.lastResult = CheckResult{Success: true, Severity: SeverityDebug, Duration: 1500 * time.Millisecond, Metrics: map[string]float64{"b": 2, "a": 1}}
+	job.next = time.Now().Add(time.Hour)
+
+	s := testScheduler(t, Config{}, &memoryStore{}, job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		s.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	app := NewApp(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	app.loadErrors = 2
+
+	metrics := NewMetricsServer(slog.New(slog.NewTextHandler(io.Discard, nil)), app, s, "")
+
+	rec := httptest.NewRecorder()
+	metrics.serveMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != metricsContentType {
+		t.Fatalf("got %d with content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`uptime_gopher_job_state{domain="example.com",check="test",state="UNKNOWN"} 1`,
+		`uptime_gopher_job_state{domain="example.com",check="test",state="UP"} 0`,
+		`uptime_gopher_job_last_run_timestamp_seconds{domain="example.com",check="test"} 1.7e+09`,
+		`uptime_gopher_job_last_duration_seconds{domain="example.com",check="test"} 1.5`,
+		"uptime_gopher_check_metric{domain=\"example.com\",check=\"test\",metric=\"a\"} 1\n" +
+			"uptime_gopher_check_metric{domain=\"example.com\",check=\"test\",metric=\"b\"} 2\n",
+		"uptime_gopher_scheduler_jobs 1\n",
+		"# HELP uptime_gopher_plugin_load_errors Number of plugins that failed to load on startup.\n" +
+			"# TYPE uptime_gopher_plugin_load_errors gauge\n" +
+			"uptime_gopher_plugin_load_errors 2\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics have no %q:\n%s", want, body)
+		}
+	}
+
+	// Every family is announced once, HELP before TYPE, before its samples.
+	announced := map[string]string{}
+
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		fields := strings.Fields(line)
+
+		switch {
+		case fields[0] == "#" && fields[1] == "HELP":
+			if _, ok := announced[fields[2]]; ok {
+				t.Fatalf("family %s announced twice", fields[2])
+			}
+
+			announced[fields[2]] = "HELP"
+		case fields[0] == "#" && fields[1] == "TYPE":
+			if announced[fields[2]] != "HELP" {
+				t.Fatalf("TYPE of %s without HELP before it", fields[2])
+			}
+
+			announced[fields[2]] = "TYPE"
+		default:
+			name, _, _ := strings.Cut(fields[0], "{")
+			if announced[name] != "TYPE" {
+				t.Fatalf("sample %s before its family", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	notifyTimeout = 30 * time.Second
+	notifyBuffer  = 256
+)
+
+// Event is a state change of a job, as delivered to notifiers.
+type Event struct {
+	Job       string
+	Domain    string
+	DomainKey string
+	Check     string
+	CheckName string
+	Tags      []string
+
+	From     string
+	To       string
+	Severity Severity
+	Message  string
+
+	// Since is when the job entered From, At is when it changed to To.
+	Since time.Time
+	At    time.Time
+
+	// Incident is the ID of the incident opened for the outage, or 0.
+	Incident int64
+}
+
+// Notification is a batch of events for one configured notifier instance.
+// Group holds the labels the events were grouped by. Notifications sent by an
+// escalation policy carry its name and the step that fired, counted from 1.
+type Notification struct {
+	Receiver string
+	Group    map[string]string
+	Events   []Event
+
+	Escalation string
+	Step       int
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// NotifierFactory builds a notifier instance from its config arguments.
+type NotifierFactory func(name string, args map[string]string) (Notifier, error)
+
+func newEvent(job *Job, change StateChange) Event {
+	return Event{
+		Job:       job.Key(),
+		Domain:    job.domain.Domain,
+		DomainKey: job.domain.Key,
+		Check:     job.checkConfig.Key,
+		CheckName: job.check.Name,
+		Tags:      job.Tags(),
+
+		From:     change.From.String(),
+		To:       change.To.String(),
+		Severity: change.Result.Severity,
+		Message:  change.Result.Message,
+
+		Since: change.Since,
+		At:    change.At,
+
+		Incident: change.Incident,
+	}
+}
+
+// Dispatcher delivers events to the notifiers of the routes they match. It
+// runs apart from the scheduler, so a slow notifier never delays checks.
+type Dispatcher struct {
+	log *slog.Logger
+
+	mu        sync.Mutex
+	root      *routeNode
+	notifiers map[string]Notifier
+	groups    map[string]*eventGroup
+
+	policies          map[string]*EscalationPolicy
+	domainEscalations map[string]string
+	escalations       map[string]*escalation
+
+	events chan queuedEvent
+	wg     sync.WaitGroup
+	done   chan struct{}
+}
+
+func NewDispatcher(log *slog.Logger, config Config, notifiers map[string]Notifier) (*Dispatcher, error) {
+	log = log.With("service", "Dispatcher")
+
+	root, err := compileRoutes(config)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := compileEscalations(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dispatcher{
+		log: log,
+
+		root:      root,
+		notifiers: notifiers,
+		groups:    map[string]*eventGroup{},
+
+		policies:          policies,
+		domainEscalations: domainEscalations(config),
+		escalations:       map[string]*escalation{},
+
+		events: make(chan queuedEvent, notifyBuffer),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// queuedEvent is an event waiting in the dispatcher queue. Muted events are
+// not sent to any notifier, they only end the escalations of a job that is
+// no longer down.
+type queuedEvent struct {
+	Event
+
+	muted bool
+}
+
+// Send queues an event. Events are dropped if the queue is full.
+func (d *Dispatcher) Send(event Event) {
+	d.queue(queuedEvent{Event: event})
+}
+
+// SendMuted queues an event that must not be notified, like a state change
+// during maintenance. It goes through the same queue as Send, so a recovery
+// never overtakes the outage it ends.
+func (d *Dispatcher) SendMuted(event Event) {
+	d.queue(queuedEvent{Event: event, muted: true})
+}
+
+func (d *Dispatcher) queue(event queuedEvent) {
+	select {
+	case d.events <- event:
+	default:
+		d.log.Warn("Notification queue full. Dropping event", "job", event.Job, "to", event.To)
+	}
+}
+
+// Reload replaces the routes, escalation policies and notifier instances.
+// Groups that are already waiting and running escalations keep their route
+// or policy and are sent to the new notifier instances.
+func (d *Dispatcher) Reload(config Config, notifiers map[string]Notifier) error {
+	root, err := compileRoutes(config)
+	if err != nil {
+		return err
+	}
+
+	policies, err := compileEscalations(config)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.root = root
+	d.notifiers = notifiers
+	d.policies = policies
+	d.domainEscalations = domainEscalations(config)
+
+	return nil
+}
+
+// Run delivers events until Close is called.
+func (d *Dispatcher) Run() {
+	defer close(d.done)
+
+	for event := range d.events {
+		d.handle(event)
+	}
+
+	d.stopEscalations()
+	d.flushAll()
+
+	d.wg.Wait()
+}
+
+// Close stops accepting events, sends the groups that are still waiting and
+// waits for the deliveries to finish.
+func (d *Dispatcher) Close() {
+	close(d.events)
+
+	<-d.done
+}
+
+func (d *Dispatcher) handle(event queuedEvent) {
+	if !event.muted {
+		d.route(event.Event)
+
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if event.To != StateDown.String() {
+		d.endEscalations(event.Job)
+	}
+}
+
+func (d *Dispatcher) route(event Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	routes := []*routeNode{}
+	if d.root != nil && d.root.matches(event) {
+		routes = d.root.match(event)
+	}
+
+	d.escalate(event, routes)
+
+	for _, route := range routes {
+		key, labels := route.group(event)
+
+		if route.groupWait == 0 && route.groupInterval == 0 {
+			d.notify(route, labels, []Event{event})
+
+			continue
+		}
+
+		group, ok := d.groups[key]
+		if !ok {
+			group = &eventGroup{
+				route:  route,
+				key:    key,
+				labels: labels,
+			}
+
+			group.timer = time.AfterFunc(route.groupWait, func() {
+				d.flush(group)
+			})
+
+			d.groups[key] = group
+		}
+
+		group.events = append(group.events, event)
+	}
+}
+
+// flush sends the events the group collected. The group stays around for
+// GroupInterval to batch later events, and is dropped once an interval
+// passes without any.
+func (d *Dispatcher) flush(group *eventGroup) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.groups[group.key] != group {
+		return
+	}
+
+	if len(group.events) == 0 || group.route.groupInterval == 0 {
+		delete(d.groups, group.key)
+	} else {
+		group.timer = time.AfterFunc(group.route.groupInterval, func() {
+			d.flush(group)
+		})
+	}
+
+	if len(group.events) > 0 {
+		d.notify(group.route, group.labels, group.events)
+
+		group.events = nil
+	}
+}
+
+func (d *Dispatcher) flushAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, group := range d.groups {
+		group.timer.Stop()
+
+		if len(group.events) > 0 {
+			d.notify(group.route, group.labels, group.events)
+		}
+
+		delete(d.groups, key)
+	}
+}
+
+func (d *Dispatcher) notify(route *routeNode, labels map[string]string, events []Event) {
+	for _, name := range route.Notifiers {
+		notifier, ok := d.notifiers[name]
+		if !ok {
+			d.log.Warn("Notifier no longer exists. Dropping notification", "receiver", name, "events", len(events))
+
+			continue
+		}
+
+		d.deliver(notifier, Notification{
+			Receiver: name,
+			Group:    labels,
+			Events:   events,
+		})
+	}
+}
+
+func (d *Dispatcher) deliver(notifier Notifier, notification Notification) {
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				d.log.Error("Notifier panicked", "receiver", notification.Receiver, "error", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+
+		err := notifier.Notify(ctx, notification)
+		if err != nil {
+			d.log.Error("Notification failed", "receiver", notification.Receiver, "events", len(notification.Events), "error", err)
+
+			return
+		}
+
+		d.log.Debug("Notification sent", "receiver", notification.Receiver, "events", len(notification.Events))
+	}()
+}
+
+// validateNotifiers checks that notifier names are unique, their kinds exist
+// and the route tree is valid.
+func (a *App) validateNotifiers(config Config) error {
+	names := map[string]bool{}
+
+	for _, notifierConfig := range config.Notifiers {
+		if notifierConfig.Name == "" {
+			return fmt.Errorf("notifier of kind %s needs a name", notifierConfig.Kind)
+		}
+
+		if names[notifierConfig.Name] {
+			return fmt.Errorf("notifier %s is configured twice", notifierConfig.Name)
+		}
+
+		names[notifierConfig.Name] = true
+
+		if _, ok := a.notifiers[notifierConfig.Kind]; !ok {
+			return fmt.Errorf("notifier %s: kind %q not found", notifierConfig.Name, notifierConfig.Kind)
+		}
+	}
+
+	_, err := compileEscalations(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = compileRoutes(config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BuildNotifiers creates the configured notifier instances by name.
+func (a *App) BuildNotifiers(config Config) (map[string]Notifier, error) {
+	notifiers := map[string]Notifier{}
+
+	for _, notifierConfig := range config.Notifiers {
+		factory, ok := a.notifiers[notifierConfig.Kind]
+		if !ok {
+			return nil, fmt.Errorf("notifier %s: kind %q not found", notifierConfig.Name, notifierConfig.Kind)
+		}
+
+		notifier, err := factory(notifierConfig.Name, notifierConfig.Args)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", notifierConfig.Name, err)
+		}
+
+		if notifier == nil {
+			return nil, fmt.Errorf("notifier %s: factory returned no notifier", notifierConfig.Name)
+		}
+
+		notifiers[notifierConfig.Name] = notifier
+	}
+
+	return notifiers, nil
+}
@@ -5,6 +5,7 @@ import (
 	"go/constant"
 	"go/token"
 	"net"
+	"path"
 	"reflect"
 
 	"github.com/traefik/yaegi/interp"
@@ -21,6 +22,15 @@ func init() {
 		"Check":       reflect.ValueOf((*Check)(nil)),
 		"CheckResult": reflect.ValueOf((*CheckResult)(nil)),
 
+		"CheckFactory": reflect.ValueOf((*CheckFactory)(nil)),
+
+		"Sleep": reflect.ValueOf(Sleep),
+
+		"Event":           reflect.ValueOf((*Event)(nil)),
+		"Notification":    reflect.ValueOf((*Notification)(nil)),
+		"Notifier":        reflect.ValueOf((*Notifier)(nil)),
+		"NotifierFactory": reflect.ValueOf((*NotifierFactory)(nil)),
+
 		"Severity":        reflect.ValueOf((*Severity)(nil)),
 		"SeverityDebug":   reflect.ValueOf(SeverityDebug),
 		"SeverityNotice":  reflect.ValueOf(SeverityNotice),
@@ -30,6 +40,15 @@ func init() {
 		"SeverityFatal":   reflect.ValueOf(SeverityFatal),
 	}
 
+	// yaegi looks up interface wrappers by the package path of the interface
+	// type. That is main for the types above, or the module path when they
+	// are built for tests.
+	wrappers := reflect.TypeOf((*Notifier)(nil)).Elem().PkgPath()
+
+	Symbols[wrappers+"/"+path.Base(wrappers)] = map[string]reflect.Value{
+		"_Notifier": reflect.ValueOf((*_uptime_gopher_Notifier)(nil)),
+	}
+
 	Symbols["golang.org/x/text/unicode/bidi/bidi"] = map[string]reflect.Value{
 		// function, constant and variable definitions
 		"AL":               reflect.ValueOf(bidi.AL),
@@ -127,6 +146,16 @@ func init() {
 	}
 }
 
+// _uptime_gopher_Notifier is an interface wrapper for Notifier type
+type _uptime_gopher_Notifier struct {
+	IValue  interface{}
+	WNotify func(ctx context.Context, notification Notification) error
+}
+
+func (W _uptime_gopher_Notifier) Notify(ctx context.Context, notification Notification) error {
+	return W.WNotify(ctx, notification)
+}
+
 // _golang_org_x_net_proxy_ContextDialer is an interface wrapper for ContextDialer type
 type _golang_org_x_net_proxy_ContextDialer struct {
 	IValue       interface{}
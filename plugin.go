@@ -1,5 +1,13 @@
 package main
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Severity int
 
 const (
@@ -11,15 +19,140 @@ const (
 	SeverityFatal
 )
 
+func (s Severity) String() string {
+	switch s {
+	case SeverityDebug:
+		return "DEBUG"
+	case SeverityNotice:
+		return "NOTICE"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityDown:
+		return "DOWN"
+	case SeverityFatal:
+		return "FATAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func parseSeverity(name string) (Severity, error) {
+	for s := SeverityDebug; s <= SeverityFatal; s++ {
+		if strings.EqualFold(s.String(), name) {
+			return s, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := parseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = severity
+
+	return nil
+}
+
+// CheckResult is what a check reports. Duration is measured by the core and
+// overwrites whatever the check sets. Metrics are optional measurements by
+// name, like "ttfb_seconds" or "cert_expiry_days".
 type CheckResult struct {
 	Success  bool
 	Severity Severity
 	Message  string
+	TimedOut bool
+	Duration time.Duration
+	Metrics  map[string]float64
+}
+
+// Sleep waits for d, or until ctx is done. Plugins wait with it instead of a
+// select statement: yaegi keeps the state of an interpreted select per
+// statement, so goroutines running the same select at once race on it.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 type Check struct {
 	Key          string
 	Name         string
 	Run          func(string, map[string]string) CheckResult
+	RunContext   func(context.Context, string, map[string]string) CheckResult
 	ValidateArgs func(map[string]string) error
 }
+
+// CheckFactory builds a new check instance. Checks registered through a
+// factory get one instance per job, so they can keep per-target state.
+type CheckFactory func() Check
+
+// run executes the check under ctx. RunContext is preferred; checks that only
+// provide Run are adapted by abandoning them once ctx is done, since they
+// have no way to observe the cancellation themselves.
+func (c Check) run(ctx context.Context, address string, args map[string]string) CheckResult {
+	done := make(chan CheckResult, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- CheckResult{
+					Success:  false,
+					Severity: SeverityFatal,
+					Message:  fmt.Sprintf("check panicked: %v", err),
+				}
+			}
+		}()
+
+		if c.RunContext != nil {
+			done <- c.RunContext(ctx, address, args)
+
+			return
+		}
+
+		done <- c.Run(address, args)
+	}()
+
+	select {
+	case result := <-done:
+		if !result.Success && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return timeoutResult()
+		}
+
+		return result
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return timeoutResult()
+		}
+
+		return CheckResult{
+			Success:  false,
+			Severity: SeverityDebug,
+			Message:  ctx.Err().Error(),
+		}
+	}
+}
+
+func timeoutResult() CheckResult {
+	return CheckResult{
+		Success:  false,
+		Severity: SeverityError,
+		Message:  "check timed out",
+		TimedOut: true,
+	}
+}
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -16,14 +17,14 @@ type CheckDomain struct {
 
 // TODO: Add support for RDAP
 // RDAP: https://data.iana.org/rdap/dns.json and add /domain/github.com to the end
-// func (c *CheckDomain) Check(address string, args map[string]string) uptimegopher.CheckResult {
+// func (c *CheckDomain) Check(ctx context.Context, address string, args map[string]string) uptimegopher.CheckResult {
 // 	return uptimegopher.CheckResult{
 // 		Success: true,
 // 	}
 // }
 
 // Whois Check
-func (c *CheckDomain) Check(address string, args map[string]string) uptimegopher.CheckResult {
+func (c *CheckDomain) Check(ctx context.Context, address string, args map[string]string) uptimegopher.CheckResult {
 	notifyAfterRaw, ok := args["notify_after"]
 	if !ok || notifyAfterRaw == "" {
 		notifyAfterRaw = "720h"
@@ -65,7 +66,12 @@ func (c *CheckDomain) Check(address string, args map[string]string) uptimegopher
 		}
 	}
 
-	raw, err := whois.Whois(url.Hostname())
+	client := whois.NewClient()
+	if deadline, ok := ctx.Deadline(); ok {
+		client.SetTimeout(time.Until(deadline))
+	}
+
+	raw, err := client.Whois(url.Hostname())
 	if err != nil {
 		return uptimegopher.CheckResult{
 			Success:  false,
@@ -126,9 +132,9 @@ func DomainCheck() uptimegopher.Check {
 	checker := &CheckDomain{}
 
 	return uptimegopher.Check{
-		Key:  "dns",
-		Name: "Domain Check",
-		Run:  checker.Check,
+		Key:        "dns",
+		Name:       "Domain Check",
+		RunContext: checker.Check,
 		ValidateArgs: func(args map[string]string) error {
 			notifyAfter, ok := args["notify_after"]
 			if ok {
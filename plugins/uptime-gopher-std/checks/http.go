@@ -2,22 +2,23 @@ package checks
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strconv"
-	"sync/atomic"
+	"strings"
+	"sync"
 	"time"
 
 	uptimegopher "uptime-gopher/uptime-gopher"
 )
 
-type CheckHttp struct {
-	failed atomic.Uint32
-}
+type CheckHttp struct{}
 
-func (hc *CheckHttp) Check(address string, args map[string]string) uptimegopher.CheckResult {
+func (hc *CheckHttp) Check(ctx context.Context, address string, args map[string]string) uptimegopher.CheckResult {
 	method, ok := args["method"]
 	if !ok || method == "" {
 		method = "GET"
@@ -28,15 +29,12 @@ func (hc *CheckHttp) Check(address string, args map[string]string) uptimegopher.
 		successCode = "200"
 	}
 
-	retriesRaw, ok := args["retries"]
-	if !ok || retriesRaw == "" {
-		retriesRaw = "3"
+	if !strings.HasPrefix(address, "https://") && !strings.HasPrefix(address, "http://") {
+		address = "https://" + address
 	}
 
-	retries, err := strconv.Atoi(retriesRaw)
+	url, err := url.Parse(address)
 	if err != nil {
-		hc.failed.Add(1)
-
 		return uptimegopher.CheckResult{
 			Success:  false,
 			Severity: uptimegopher.SeverityFatal,
@@ -44,44 +42,36 @@ func (hc *CheckHttp) Check(address string, args map[string]string) uptimegopher.
 		}
 	}
 
-	timeoutRaw, ok := args["timeout"]
-	if !ok || timeoutRaw == "" {
-		timeoutRaw = "5s"
-	}
+	// The trace hooks may still run after Do returned, for example for a
+	// connection that keeps dialing in the background, so they only record
+	// the timings under mu and the metrics are built from a copy.
+	var mu sync.Mutex
+	var started, tlsStarted time.Time
+	var tlsHandshake, ttfb time.Duration
 
-	timeout, err := time.ParseDuration(timeoutRaw)
-	if err != nil {
-		hc.failed.Add(1)
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
 
-		return uptimegopher.CheckResult{
-			Success:  false,
-			Severity: uptimegopher.SeverityFatal,
-			Message:  err.Error(),
-		}
-	}
-
-	url, err := url.Parse(address)
-	if err != nil {
-		hc.failed.Add(1)
+			tlsStarted = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
 
-		return uptimegopher.CheckResult{
-			Success:  false,
-			Severity: uptimegopher.SeverityFatal,
-			Message:  err.Error(),
-		}
-	}
+			tlsHandshake = time.Since(tlsStarted)
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
 
-	if url.Scheme == "" {
-		url.Scheme = "https"
+			ttfb = time.Since(started)
+		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, method, url.String(), nil)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, url.String(), nil)
 	if err != nil {
-		hc.failed.Add(1)
-
 		return uptimegopher.CheckResult{
 			Success:  false,
 			Severity: uptimegopher.SeverityFatal,
@@ -89,20 +79,12 @@ func (hc *CheckHttp) Check(address string, args map[string]string) uptimegopher.
 		}
 	}
 
+	mu.Lock()
+	started = time.Now()
+	mu.Unlock()
+
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		if hc.failed.Load() < uint32(retries) {
-			hc.failed.Add(1)
-
-			return uptimegopher.CheckResult{
-				Success:  false,
-				Severity: uptimegopher.SeverityDebug,
-				Message:  err.Error(),
-			}
-		}
-
-		hc.failed.Add(1)
-
 		return uptimegopher.CheckResult{
 			Success:  false,
 			Severity: uptimegopher.SeverityError,
@@ -113,30 +95,34 @@ func (hc *CheckHttp) Check(address string, args map[string]string) uptimegopher.
 	defer resp.Body.Close()
 	defer io.Copy(io.Discard, resp.Body)
 
-	if strconv.Itoa(resp.StatusCode) != successCode {
-		if hc.failed.Load() < uint32(retries) {
-			hc.failed.Add(1)
+	metrics := map[string]float64{
+		"status_code": float64(resp.StatusCode),
+	}
 
-			return uptimegopher.CheckResult{
-				Success:  false,
-				Severity: uptimegopher.SeverityDebug,
-				Message:  fmt.Sprintf("status code is not as expected: %d", resp.StatusCode),
-			}
-		}
+	mu.Lock()
+
+	if tlsHandshake > 0 {
+		metrics["tls_handshake_seconds"] = tlsHandshake.Seconds()
+	}
+
+	if ttfb > 0 {
+		metrics["ttfb_seconds"] = ttfb.Seconds()
+	}
 
-		hc.failed.Add(1)
+	mu.Unlock()
 
+	if strconv.Itoa(resp.StatusCode) != successCode {
 		return uptimegopher.CheckResult{
 			Success:  false,
 			Severity: uptimegopher.SeverityError,
 			Message:  fmt.Sprintf("status code is not as expected: %d", resp.StatusCode),
+			Metrics:  metrics,
 		}
 	}
 
-	hc.failed.Store(0)
-
 	return uptimegopher.CheckResult{
 		Success: true,
+		Metrics: metrics,
 	}
 }
 
@@ -144,10 +130,19 @@ func HttpCheck() uptimegopher.Check {
 	checker := &CheckHttp{}
 
 	return uptimegopher.Check{
-		Key:  "http",
-		Name: "Http Check",
-		Run:  checker.Check,
+		Key:        "http",
+		Name:       "Http Check",
+		RunContext: checker.Check,
 		ValidateArgs: func(args map[string]string) error {
+			// Both were arguments of the check before the core took them over.
+			if _, ok := args["timeout"]; ok {
+				return fmt.Errorf("timeout is no longer an argument, set the timeout of the check instead (default 30s)")
+			}
+
+			if _, ok := args["retries"]; ok {
+				return fmt.Errorf("retries is no longer an argument, set the failure_threshold of the check instead")
+			}
+
 			method, ok := args["method"]
 			if ok {
 				if method != "GET" && method != "POST" {
@@ -167,18 +162,6 @@ func HttpCheck() uptimegopher.Check {
 				}
 			}
 
-			retries, ok := args["retries"]
-			if ok {
-				num, err := strconv.Atoi(retries)
-				if err != nil {
-					return fmt.Errorf("retries must be a number")
-				}
-
-				if num < 1 || num > 10 {
-					return fmt.Errorf("retries must be between 1 and 10")
-				}
-			}
-
 			return nil
 		},
 	}
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -12,7 +13,7 @@ import (
 
 type CheckSsl struct{}
 
-func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.CheckResult {
+func (c *CheckSsl) Check(ctx context.Context, address string, args map[string]string) uptimegopher.CheckResult {
 	notifyAfterRaw, ok := args["notify_after"]
 	if !ok || notifyAfterRaw == "" {
 		notifyAfterRaw = "720h"
@@ -56,7 +57,9 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 
 	addr := net.JoinHostPort(url.Hostname(), "https")
 
-	conn, err := net.Dial("tcp", addr)
+	dialer := net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return uptimegopher.CheckResult{
 			Success:  false,
@@ -70,7 +73,9 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 		ServerName: url.Hostname(),
 	})
 
-	err = tlsConn.Handshake()
+	handshakeStarted := time.Now()
+
+	err = tlsConn.HandshakeContext(ctx)
 	if err != nil {
 		return uptimegopher.CheckResult{
 			Success:  false,
@@ -79,6 +84,10 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 		}
 	}
 
+	metrics := map[string]float64{
+		"tls_handshake_seconds": time.Since(handshakeStarted).Seconds(),
+	}
+
 	state := tlsConn.ConnectionState()
 
 	if len(state.PeerCertificates) == 0 {
@@ -86,6 +95,7 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 			Success:  false,
 			Severity: uptimegopher.SeverityDown,
 			Message:  "No certificates found",
+			Metrics:  metrics,
 		}
 	}
 
@@ -99,11 +109,14 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 		}
 	}
 
+	metrics["cert_expiry_days"] = validBefore.Sub(now).Hours() / 24
+
 	if validBefore.Before(now) {
 		return uptimegopher.CheckResult{
 			Success:  false,
 			Severity: uptimegopher.SeverityDown,
 			Message:  fmt.Sprintf("Certificate is not valid anymore. Expiration date: %s", validBefore.Format(time.RFC1123)),
+			Metrics:  metrics,
 		}
 	}
 
@@ -112,6 +125,7 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 			Success:  false,
 			Severity: uptimegopher.SeverityWarning,
 			Message:  fmt.Sprintf("Certificate is about to expire. Expiration date: %s", validBefore.Format(time.RFC1123)),
+			Metrics:  metrics,
 		}
 	}
 
@@ -120,11 +134,13 @@ func (c *CheckSsl) Check(address string, args map[string]string) uptimegopher.Ch
 			Success:  false,
 			Severity: uptimegopher.SeverityError,
 			Message:  fmt.Sprintf("Certificate is about to expire. Expiration date: %s", validBefore.Format(time.RFC1123)),
+			Metrics:  metrics,
 		}
 	}
 
 	return uptimegopher.CheckResult{
 		Success: true,
+		Metrics: metrics,
 	}
 }
 
@@ -132,9 +148,9 @@ func SslCheck() uptimegopher.Check {
 	checker := &CheckSsl{}
 
 	return uptimegopher.Check{
-		Key:  "ssl",
-		Name: "Ssl Check",
-		Run:  checker.Check,
+		Key:        "ssl",
+		Name:       "Ssl Check",
+		RunContext: checker.Check,
 		ValidateArgs: func(args map[string]string) error {
 			notifyAfter, ok := args["notify_after"]
 			if ok {
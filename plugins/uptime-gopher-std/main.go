@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"uptime-gopher-std/checks"
 )
@@ -8,7 +9,7 @@ import (
 func main() {
 	check := checks.DomainCheck()
 
-	result := check.Run("google.com", map[string]string{})
+	result := check.RunContext(context.Background(), "google.com", map[string]string{})
 
 	fmt.Println("Success:", result.Success)
 	fmt.Println("Severity:", result.Severity)
@@ -0,0 +1,393 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	uptimegopher "uptime-gopher/uptime-gopher"
+)
+
+const (
+	emailTLSStartTLS = "starttls"
+	emailTLSImplicit = "implicit"
+	emailTLSNone     = "none"
+
+	defaultEmailTimeout = 30 * time.Second
+)
+
+const defaultEmailSubject = `{{ if .Escalation }}[Escalation {{ .Step }}] {{ end }}[{{ (index .Events 0).To }}] {{ (index .Events 0).Job }}{{ if gt (len .Events) 1 }} and {{ len (slice .Events 1) }} more{{ end }}`
+
+const defaultEmailText = `{{ range .Events }}{{ .CheckName }} on {{ .Domain }} is {{ .To }} (was {{ .From }})
+Severity: {{ .Severity }}
+Message:  {{ .Message }}
+At:       {{ .At.Format "2006-01-02 15:04:05 MST" }}
+
+{{ end }}`
+
+const defaultEmailHTML = `<table>
+{{ range .Events }}<tr><td><b>{{ .CheckName }}</b> on {{ .Domain }}</td><td>{{ .From }} &rarr; <b>{{ .To }}</b></td><td>{{ .Severity }}</td><td>{{ .Message }}</td><td>{{ .At.Format "2006-01-02 15:04:05 MST" }}</td></tr>
+{{ end }}</table>`
+
+// Email sends notifications over SMTP as multipart messages with a text and
+// an HTML part.
+type Email struct {
+	name string
+
+	host     string
+	port     string
+	security string
+	tls      *tls.Config
+	auth     string
+	username string
+	password string
+	timeout  time.Duration
+
+	from *mail.Address
+	to   []*mail.Address
+
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+
+	// Dial opens the connection to the server. It can be replaced to talk to
+	// an in-process stub.
+	Dial func(ctx context.Context, network string, address string) (net.Conn, error)
+}
+
+// NewEmail builds an email notifier. Arguments:
+//
+//	host             SMTP server, required
+//	port             default 587, or 465 with implicit TLS
+//	tls              starttls, implicit or none, default starttls
+//	tls_skip_verify  do not verify the server certificate
+//	auth             plain or login, default plain if username is set
+//	username         account name
+//	password         account password
+//	from             sender address, required
+//	to               comma separated recipients, required
+//	subject          text/template for the subject
+//	text             text/template for the text part
+//	html             html/template for the HTML part
+//	timeout          timeout of a delivery, default 30s
+func NewEmail(name string, args map[string]string) (uptimegopher.Notifier, error) {
+	var dialer net.Dialer
+
+	email := &Email{
+		name: name,
+
+		host:     args["host"],
+		port:     args["port"],
+		security: args["tls"],
+		auth:     args["auth"],
+		username: args["username"],
+		password: args["password"],
+		timeout:  defaultEmailTimeout,
+
+		Dial: dialer.DialContext,
+	}
+
+	if email.host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+
+	if email.security == "" {
+		email.security = emailTLSStartTLS
+	}
+
+	switch email.security {
+	case emailTLSStartTLS, emailTLSNone:
+		if email.port == "" {
+			email.port = "587"
+		}
+	case emailTLSImplicit:
+		if email.port == "" {
+			email.port = "465"
+		}
+	default:
+		return nil, fmt.Errorf("tls must be %s, %s or %s", emailTLSStartTLS, emailTLSImplicit, emailTLSNone)
+	}
+
+	email.tls = &tls.Config{
+		ServerName:         email.host,
+		InsecureSkipVerify: args["tls_skip_verify"] == "true",
+	}
+
+	if email.auth == "" && email.username != "" {
+		email.auth = "plain"
+	}
+
+	if email.auth != "" && email.auth != "plain" && email.auth != "login" {
+		return nil, fmt.Errorf("auth must be plain or login")
+	}
+
+	if email.auth != "" && email.username == "" {
+		return nil, fmt.Errorf("auth requires a username")
+	}
+
+	var err error
+
+	email.from, err = mail.ParseAddress(args["from"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+
+	email.to, err = mail.ParseAddressList(args["to"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid to addresses: %w", err)
+	}
+
+	if timeout, ok := args["timeout"]; ok {
+		email.timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+
+	email.subject, err = template.New("subject").Parse(argOr(args, "subject", defaultEmailSubject))
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+
+	email.text, err = template.New("text").Parse(argOr(args, "text", defaultEmailText))
+	if err != nil {
+		return nil, fmt.Errorf("invalid text template: %w", err)
+	}
+
+	email.html, err = htmltemplate.New("html").Parse(argOr(args, "html", defaultEmailHTML))
+	if err != nil {
+		return nil, fmt.Errorf("invalid html template: %w", err)
+	}
+
+	return email, nil
+}
+
+func argOr(args map[string]string, key string, fallback string) string {
+	if value, ok := args[key]; ok && value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func (e *Email) Notify(ctx context.Context, notification uptimegopher.Notification) error {
+	if len(notification.Events) == 0 {
+		return nil
+	}
+
+	message, err := e.message(notification)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.send(ctx, message)
+}
+
+func (e *Email) send(ctx context.Context, message []byte) error {
+	address := net.JoinHostPort(e.host, e.port)
+
+	conn, err := e.Dial(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if e.security == emailTLSImplicit {
+		tlsConn := tls.Client(conn, e.tls)
+
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+
+			return err
+		}
+
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+
+		return err
+	}
+	defer client.Close()
+
+	if e.security == emailTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server %s does not support STARTTLS", address)
+		}
+
+		err = client.StartTLS(e.tls)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch e.auth {
+	case "plain":
+		err = client.Auth(smtp.PlainAuth("", e.username, e.password, e.host))
+	case "login":
+		err = client.Auth(&loginAuth{username: e.username, password: e.password, host: e.host})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = client.Mail(e.from.Address)
+	if err != nil {
+		return err
+	}
+
+	for _, to := range e.to {
+		err = client.Rcpt(to.Address)
+		if err != nil {
+			return fmt.Errorf("recipient %s: %w", to.Address, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(message)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (e *Email) message(notification uptimegopher.Notification) ([]byte, error) {
+	var subject, text, html bytes.Buffer
+
+	err := e.subject.Execute(&subject, notification)
+	if err != nil {
+		return nil, fmt.Errorf("render subject: %w", err)
+	}
+
+	err = e.text.Execute(&text, notification)
+	if err != nil {
+		return nil, fmt.Errorf("render text: %w", err)
+	}
+
+	err = e.html.Execute(&html, notification)
+	if err != nil {
+		return nil, fmt.Errorf("render html: %w", err)
+	}
+
+	var message bytes.Buffer
+
+	parts := multipart.NewWriter(&message)
+
+	to := []string{}
+	for _, address := range e.to {
+		to = append(to, address.String())
+	}
+
+	headers := []string{
+		"From: " + e.from.String(),
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + messageID(e.host),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + strconv.Quote(parts.Boundary()),
+	}
+
+	message.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		encoder.Write(part.body)
+		encoder.Close()
+	}
+
+	parts.Close()
+
+	return message.Bytes(), nil
+}
+
+func messageID(host string) string {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return "<" + hex.EncodeToString(id) + "@" + host + ">"
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like PlainAuth, only send credentials over TLS or to localhost.
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, fmt.Errorf("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, fmt.Errorf("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("unexpected server challenge %q", fromServer)
+}
@@ -0,0 +1,282 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	uptimegopher "uptime-gopher/uptime-gopher"
+)
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+	defaultSignatureName  = "X-Uptime-Gopher-Signature"
+)
+
+// Webhook posts notifications to an HTTP endpoint. The body is a JSON payload,
+// or the output of a text/template that gets the notification as its data.
+type Webhook struct {
+	name string
+
+	url           string
+	method        string
+	contentType   string
+	headers       http.Header
+	template      *template.Template
+	secret        []byte
+	signatureName string
+	retries       int
+	backoff       time.Duration
+
+	client *http.Client
+}
+
+type webhookPayload struct {
+	Receiver   string            `json:"receiver"`
+	Group      map[string]string `json:"group"`
+	Escalation string            `json:"escalation,omitempty"`
+	Step       int               `json:"step,omitempty"`
+	Events     []webhookEvent    `json:"events"`
+}
+
+type webhookEvent struct {
+	Job       string    `json:"job"`
+	Domain    string    `json:"domain"`
+	DomainKey string    `json:"domain_key,omitempty"`
+	Check     string    `json:"check"`
+	CheckName string    `json:"check_name"`
+	Tags      []string  `json:"tags"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+	Since     time.Time `json:"since"`
+	At        time.Time `json:"at"`
+	Incident  int64     `json:"incident,omitempty"`
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		return string(data), nil
+	},
+}
+
+// NewWebhook builds a webhook notifier. Arguments:
+//
+//	url               endpoint, required
+//	method            POST or PUT, default POST
+//	content_type      default application/json
+//	headers           extra headers, one "Name: value" per line
+//	template          text/template for the body
+//	secret            key for the HMAC-SHA256 signature of the body
+//	signature_header  default X-Uptime-Gopher-Signature
+//	retries           retries after a failed attempt, default 3
+//	backoff           delay before the first retry, doubled on each one
+//	timeout           timeout of a single attempt
+func NewWebhook(name string, args map[string]string) (uptimegopher.Notifier, error) {
+	webhook := &Webhook{
+		name: name,
+
+		url:           args["url"],
+		method:        args["method"],
+		contentType:   args["content_type"],
+		headers:       http.Header{},
+		secret:        []byte(args["secret"]),
+		signatureName: args["signature_header"],
+		retries:       defaultWebhookRetries,
+		backoff:       defaultWebhookBackoff,
+
+		client: &http.Client{},
+	}
+
+	endpoint, err := url.Parse(webhook.url)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+
+	if webhook.method == "" {
+		webhook.method = http.MethodPost
+	}
+
+	if webhook.method != http.MethodPost && webhook.method != http.MethodPut {
+		return nil, fmt.Errorf("method must be POST or PUT")
+	}
+
+	if webhook.contentType == "" {
+		webhook.contentType = "application/json"
+	}
+
+	if webhook.signatureName == "" {
+		webhook.signatureName = defaultSignatureName
+	}
+
+	for _, line := range strings.Split(args["headers"], "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+
+		webhook.headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	if text, ok := args["template"]; ok {
+		webhook.template, err = template.New(name).Funcs(webhookFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	if retries, ok := args["retries"]; ok {
+		webhook.retries, err = strconv.Atoi(retries)
+		if err != nil || webhook.retries < 0 {
+			return nil, fmt.Errorf("retries must be a non-negative number")
+		}
+	}
+
+	if backoff, ok := args["backoff"]; ok {
+		webhook.backoff, err = time.ParseDuration(backoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backoff: %w", err)
+		}
+	}
+
+	if timeout, ok := args["timeout"]; ok {
+		webhook.client.Timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+
+	return webhook, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, notification uptimegopher.Notification) error {
+	body, err := w.render(notification)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= w.retries {
+			return err
+		}
+
+		cancelled := uptimegopher.Sleep(ctx, backoff)
+		if cancelled != nil {
+			return fmt.Errorf("%w (last error: %v)", cancelled, err)
+		}
+
+		backoff *= 2
+	}
+}
+
+func (w *Webhook) render(notification uptimegopher.Notification) ([]byte, error) {
+	if w.template != nil {
+		var buf bytes.Buffer
+
+		err := w.template.Execute(&buf, notification)
+		if err != nil {
+			return nil, fmt.Errorf("render template: %w", err)
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	payload := webhookPayload{
+		Receiver:   notification.Receiver,
+		Group:      notification.Group,
+		Escalation: notification.Escalation,
+		Step:       notification.Step,
+		Events:     []webhookEvent{},
+	}
+
+	for _, event := range notification.Events {
+		payload.Events = append(payload.Events, webhookEvent{
+			Job:       event.Job,
+			Domain:    event.Domain,
+			DomainKey: event.DomainKey,
+			Check:     event.Check,
+			CheckName: event.CheckName,
+			Tags:      event.Tags,
+			From:      event.From,
+			To:        event.To,
+			Severity:  event.Severity.String(),
+			Message:   event.Message,
+			Since:     event.Since,
+			At:        event.At,
+			Incident:  event.Incident,
+		})
+	}
+
+	return json.Marshal(payload)
+}
+
+// send makes one attempt and reports whether a failure is worth retrying.
+// Client errors other than 408 and 429 are not.
+func (w *Webhook) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	for key, values := range w.headers {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("Content-Type", w.contentType)
+
+	if len(w.secret) > 0 {
+		req.Header.Set(w.signatureName, "sha256="+sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+
+	defer resp.Body.Close()
+	defer io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+func sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"./checks"
+	"./notifiers"
 
 	uptimegopher "uptime-gopher/uptime-gopher"
 )
@@ -13,13 +14,18 @@ import (
 var Name = "Uptime Gopher Standard Plugin"
 
 func Setup(ctx *uptimegopher.PluginCtx) error {
-	// ctx.AddCheck(checks.HttpCheck())
+	ctx.AddCheck(checks.HttpCheck())
 	ctx.AddCheck(checks.DomainCheck())
 	ctx.AddCheck(checks.SslCheck())
 
+	ctx.AddNotifier("webhook", notifiers.NewWebhook)
+	ctx.AddNotifier("email", notifiers.NewEmail)
+
 	return nil
 }
 
 func Shutdown(ctx *uptimegopher.PluginCtx) error {
 	fmt.Println("shutdown")
+
+	return nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import "container/heap"
+
+// jobQueue is a min-heap of jobs ordered by their next run time.
+type jobQueue []*Job
+
+func (q jobQueue) Len() int {
+	return len(q)
+}
+
+func (q jobQueue) Less(i, j int) bool {
+	return q[i].next.Before(q[j].next)
+}
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x any) {
+	job := x.(*Job)
+	job.index = len(*q)
+
+	*q = append(*q, job)
+}
+
+func (q *jobQueue) Pop() any {
+	old := *q
+	n := len(old)
+
+	job := old[n-1]
+	old[n-1] = nil
+	job.index = -1
+
+	*q = old[:n-1]
+
+	return job
+}
+
+func (q jobQueue) peek() *Job {
+	if len(q) == 0 {
+		return nil
+	}
+
+	return q[0]
+}
+
+func newJobQueue(jobs []*Job) jobQueue {
+	q := make(jobQueue, len(jobs))
+	for i, job := range jobs {
+		job.index = i
+		q[i] = job
+	}
+
+	heap.Init(&q)
+
+	return q
+}
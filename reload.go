@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const watchInterval = 5 * time.Second
+
+// Reloader re-reads the config file on SIGHUP, or when the file changes if
+// watching is enabled, and hands the new jobs to the scheduler and the new
+// notifiers to the dispatcher. A config that
+// fails validation is ignored and the running one stays active.
+type Reloader struct {
+	log *slog.Logger
+
+	app        *App
+	scheduler  *Scheduler
+	dispatcher *Dispatcher
+
+	path    string
+	watch   bool
+	modTime time.Time
+}
+
+func NewReloader(log *slog.Logger, app *App, scheduler *Scheduler, dispatcher *Dispatcher, path string, watch bool) *Reloader {
+	log = log.With("service", "Reloader")
+
+	reloader := &Reloader{
+		log: log,
+
+		app:        app,
+		scheduler:  scheduler,
+		dispatcher: dispatcher,
+
+		path:  path,
+		watch: watch,
+	}
+
+	reloader.modTime, _ = reloader.stat()
+
+	return reloader
+}
+
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if r.watch {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.log.Info("Received SIGHUP")
+
+			r.reload()
+		case <-tick:
+			modTime, err := r.stat()
+			if err != nil || modTime.Equal(r.modTime) {
+				continue
+			}
+
+			r.log.Info("Config file changed")
+
+			r.reload()
+		}
+	}
+}
+
+func (r *Reloader) stat() (time.Time, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return info.ModTime(), nil
+}
+
+func (r *Reloader) reload() {
+	r.modTime, _ = r.stat()
+
+	r.log.Info("Reloading config...")
+
+	config, err := LoadConfig(r.path)
+	if err != nil {
+		r.log.Error("Failed to load config. Keeping the current one", "error", err)
+
+		return
+	}
+
+	err = r.app.ValidateConfig(config)
+	if err != nil {
+		r.log.Error("Config validation failed. Keeping the current one", "error", err)
+
+		return
+	}
+
+	jobs, err := r.app.BuildJobs(config, time.Now())
+	if err != nil {
+		r.log.Error("Failed to create jobs. Keeping the current config", "error", err)
+
+		return
+	}
+
+	notifiers, err := r.app.BuildNotifiers(config)
+	if err != nil {
+		r.log.Error("Failed to create notifiers. Keeping the current config", "error", err)
+
+		return
+	}
+
+	err = r.scheduler.Reload(config, jobs)
+	if err != nil {
+		r.log.Error("Failed to apply config", "error", err)
+
+		return
+	}
+
+	err = r.dispatcher.Reload(config, notifiers)
+	if err != nil {
+		r.log.Error("Failed to apply notification routes", "error", err)
+
+		return
+	}
+
+	r.log.Info("Config reloaded")
+}
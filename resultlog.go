@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt = ".log"
+
+	// frameHeader is the length of the payload followed by its CRC32.
+	frameHeader = 8
+	maxFrame    = 1 << 20
+)
+
+var errCorruptFrame = errors.New("corrupt record")
+
+// LogStore is the default result store. Results are appended to segment
+// files, and a new segment is started once the current one reaches its size
+// or age limit. Whole segments are deleted once all their results are older
+// than the retention. The index of every segment is rebuilt in memory on
+// startup and maps each job to the offsets of its results. Queries look up
+// the offsets under the lock and read the records after releasing it, so
+// a long query never holds up Append.
+type LogStore struct {
+	log *slog.Logger
+
+	mu              sync.Mutex
+	dir             string
+	retention       time.Duration
+	segmentSize     int64
+	segmentDuration time.Duration
+	segments        []*segment
+}
+
+type segment struct {
+	path    string
+	file    *os.File
+	size    int64
+	created time.Time
+
+	// first and last are the oldest and newest result in the segment.
+	first time.Time
+	last  time.Time
+	index map[string][]indexEntry
+
+	// readers counts the queries reading the segment. An expired segment
+	// is deleted right away, but its file is closed by the last reader.
+	readers int
+	expired bool
+}
+
+// segmentRead is a part of a segment index that a query reads after
+// releasing the lock.
+type segmentRead struct {
+	seg     *segment
+	entries []indexEntry
+}
+
+type indexEntry struct {
+	at     int64
+	offset int64
+}
+
+func OpenLogStore(log *slog.Logger, dir string, retention time.Duration, segmentSize int64, segmentDuration time.Duration) (*LogStore, error) {
+	log = log.With("service", "Results")
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+
+	store := &LogStore{
+		log: log,
+
+		dir:             dir,
+		retention:       retention,
+		segmentSize:     segmentSize,
+		segmentDuration: segmentDuration,
+		segments:        []*segment{},
+	}
+
+	records := 0
+
+	for i, path := range paths {
+		seg, count, err := store.openSegment(path, i == len(paths)-1)
+		if err != nil {
+			store.Close()
+
+			return nil, err
+		}
+
+		store.segments = append(store.segments, seg)
+		records += count
+	}
+
+	store.prune(time.Now())
+
+	log.Info("Result store opened", "path", dir, "segments", len(store.segments), "records", records)
+
+	return store, nil
+}
+
+// openSegment reads a segment and builds its index. A corrupt record ends
+// the segment. If it is the active segment, the rest of the file is cut off
+// so new results are appended after the last good one.
+func (s *LogStore) openSegment(path string, active bool) (*segment, int, error) {
+	nanos, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("segment %s: unexpected file name", path)
+	}
+
+	flag := os.O_RDONLY
+	if active {
+		flag = os.O_RDWR
+	}
+
+	file, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seg := &segment{
+		path:    path,
+		file:    file,
+		created: time.Unix(0, nanos),
+		index:   map[string][]indexEntry{},
+	}
+
+	reader := bufio.NewReader(file)
+	count := 0
+
+	for {
+		record, n, err := readFrame(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			s.log.Warn("Dropping the end of a damaged segment", "path", path, "offset", seg.size, "error", err)
+
+			if active {
+				err = file.Truncate(seg.size)
+				if err != nil {
+					file.Close()
+
+					return nil, 0, err
+				}
+			}
+
+			break
+		}
+
+		seg.add(record, seg.size)
+		seg.size += n
+		count++
+	}
+
+	return seg, count, nil
+}
+
+func (seg *segment) add(record ResultRecord, offset int64) {
+	seg.index[record.Job] = append(seg.index[record.Job], indexEntry{
+		at:     record.At.UnixNano(),
+		offset: offset,
+	})
+
+	if seg.first.IsZero() || record.At.Before(seg.first) {
+		seg.first = record.At
+	}
+
+	if record.At.After(seg.last) {
+		seg.last = record.At
+	}
+}
+
+func (seg *segment) read(offset int64) (ResultRecord, error) {
+	record, _, err := readFrame(io.NewSectionReader(seg.file, offset, maxFrame+frameHeader))
+	if err != nil {
+		return ResultRecord{}, fmt.Errorf("segment %s: offset %d: %w", seg.path, offset, err)
+	}
+
+	return record, nil
+}
+
+// readFrame reads one record and returns the number of bytes it took. It
+// returns io.EOF only if the reader ends before the record starts.
+func readFrame(reader io.Reader) (ResultRecord, int64, error) {
+	var header [frameHeader]byte
+
+	_, err := io.ReadFull(reader, header[:])
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ResultRecord{}, 0, errCorruptFrame
+	}
+
+	if err != nil {
+		return ResultRecord{}, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > maxFrame {
+		return ResultRecord{}, 0, errCorruptFrame
+	}
+
+	payload := make([]byte, length)
+
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return ResultRecord{}, 0, errCorruptFrame
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return ResultRecord{}, 0, errCorruptFrame
+	}
+
+	var record ResultRecord
+
+	err = json.Unmarshal(payload, &record)
+	if err != nil {
+		return ResultRecord{}, 0, errCorruptFrame
+	}
+
+	return record, frameHeader + int64(length), nil
+}
+
+func (s *LogStore) Append(record ResultRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, frameHeader+len(payload))
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:frameHeader], crc32.ChecksumIEEE(payload))
+	copy(frame[frameHeader:], payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seg, err := s.active(int64(len(frame)))
+	if err != nil {
+		return err
+	}
+
+	_, err = seg.file.WriteAt(frame, seg.size)
+	if err != nil {
+		return fmt.Errorf("segment %s: %w", seg.path, err)
+	}
+
+	seg.add(record, seg.size)
+	seg.size += int64(len(frame))
+
+	return nil
+}
+
+// active returns the segment to append n bytes to, starting a new one if
+// the current segment is full or too old.
+func (s *LogStore) active(n int64) (*segment, error) {
+	now := time.Now()
+
+	if len(s.segments) > 0 {
+		seg := s.segments[len(s.segments)-1]
+
+		if seg.size == 0 || (seg.size+n <= s.segmentSize && now.Sub(seg.created) < s.segmentDuration) {
+			return seg, nil
+		}
+
+		err := seg.file.Sync()
+		if err != nil {
+			s.log.Warn("Failed to sync segment", "path", seg.path, "error", err)
+		}
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", now.UnixNano(), segmentExt))
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{
+		path:    path,
+		file:    file,
+		created: now,
+		index:   map[string][]indexEntry{},
+	}
+
+	s.segments = append(s.segments, seg)
+
+	s.log.Debug("Segment started", "path", path)
+
+	s.prune(now)
+
+	return seg, nil
+}
+
+// prune deletes the segments whose newest result is older than the
+// retention. The active segment is always kept.
+func (s *LogStore) prune(now time.Time) {
+	cutoff := now.Add(-s.retention)
+	kept := []*segment{}
+
+	for i, seg := range s.segments {
+		newest := seg.last
+		if newest.IsZero() {
+			newest = seg.created
+		}
+
+		if i == len(s.segments)-1 || !newest.Before(cutoff) {
+			kept = append(kept, seg)
+
+			continue
+		}
+
+		seg.expired = true
+		if seg.readers == 0 {
+			seg.file.Close()
+		}
+
+		err := os.Remove(seg.path)
+		if err != nil {
+			s.log.Warn("Failed to delete expired segment", "path", seg.path, "error", err)
+		} else {
+			s.log.Info("Deleted expired segment", "path", seg.path, "newest", newest)
+		}
+	}
+
+	s.segments = kept
+}
+
+func (s *LogStore) Query(job string, from, to time.Time) ([]ResultRecord, error) {
+	s.mu.Lock()
+
+	reads := []segmentRead{}
+
+	for _, seg := range s.segments {
+		entries := seg.index[job]
+		if len(entries) == 0 || (!to.IsZero() && seg.first.After(to)) || (!from.IsZero() && seg.last.Before(from)) {
+			continue
+		}
+
+		if !from.IsZero() {
+			entries = entries[sort.Search(len(entries), func(i int) bool {
+				return entries[i].at >= from.UnixNano()
+			}):]
+		}
+
+		if !to.IsZero() {
+			entries = entries[:sort.Search(len(entries), func(i int) bool {
+				return entries[i].at > to.UnixNano()
+			})]
+		}
+
+		reads = append(reads, s.pin(seg, entries))
+	}
+
+	s.mu.Unlock()
+
+	return s.read(reads)
+}
+
+func (s *LogStore) Tail(job string, n int) ([]ResultRecord, error) {
+	s.mu.Lock()
+
+	reads := []segmentRead{}
+	count := 0
+
+	for i := len(s.segments) - 1; i >= 0 && count < n; i-- {
+		seg := s.segments[i]
+		entries := seg.index[job]
+		entries = entries[max(0, len(entries)-(n-count)):]
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		reads = append([]segmentRead{s.pin(seg, entries)}, reads...)
+		count += len(entries)
+	}
+
+	s.mu.Unlock()
+
+	return s.read(reads)
+}
+
+// pin keeps the file of seg open until the read is done. The entries are
+// never changed once appended, so they can be read without the lock.
+func (s *LogStore) pin(seg *segment, entries []indexEntry) segmentRead {
+	seg.readers++
+
+	return segmentRead{
+		seg:     seg,
+		entries: entries,
+	}
+}
+
+// read decodes the records of reads and unpins their segments.
+func (s *LogStore) read(reads []segmentRead) ([]ResultRecord, error) {
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for _, read := range reads {
+			read.seg.readers--
+
+			if read.seg.readers == 0 && read.seg.expired {
+				read.seg.file.Close()
+			}
+		}
+	}()
+
+	records := []ResultRecord{}
+
+	for _, read := range reads {
+		for _, entry := range read.entries {
+			record, err := read.seg.read(entry.offset)
+			if err != nil {
+				return nil, err
+			}
+
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func (s *LogStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+
+	if len(s.segments) > 0 {
+		errs = append(errs, s.segments[len(s.segments)-1].file.Sync())
+	}
+
+	for _, seg := range s.segments {
+		errs = append(errs, seg.file.Close())
+	}
+
+	s.segments = nil
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestLogStore(t *testing.T, dir string, retention time.Duration, segmentSize int64) *LogStore {
+	t.Helper()
+
+	store, err := OpenLogStore(slog.New(slog.NewTextHandler(io.Discard, nil)), dir, retention, segmentSize, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func appendTestRecords(t *testing.T, store *LogStore, job string, from time.Time, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		err := store.Append(ResultRecord{
+			Job:     job,
+			At:      from.Add(time.Duration(i) * time.Minute),
+			Success: i%2 == 0,
+			Message: job,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func recordTimes(records []ResultRecord) []time.Time {
+	times := make([]time.Time, len(records))
+	for i, record := range records {
+		times[i] = record.At
+	}
+
+	return times
+}
+
+func checkRecordTimes(t *testing.T, records []ResultRecord, from time.Time, minutes ...int) {
+	t.Helper()
+
+	if len(records) != len(minutes) {
+		t.Fatalf("got %d records %v, want %d", len(records), recordTimes(records), len(minutes))
+	}
+
+	for i, minute := range minutes {
+		want := from.Add(time.Duration(minute) * time.Minute)
+		if !records[i].At.Equal(want) {
+			t.Fatalf("record %d at %s, want %s", i, records[i].At, want)
+		}
+	}
+}
+
+func TestLogStoreQueryAndTail(t *testing.T) {
+	from := time.Now().Add(-time.Hour).Truncate(time.Minute)
+
+	// A small segment size spreads the results over several segments.
+	store := openTestLogStore(t, t.TempDir(), 24*time.Hour, 512)
+	defer store.Close()
+
+	appendTestRecords(t, store, "a/http", from, 10)
+	appendTestRecords(t, store, "b/http", from, 3)
+
+	if len(store.segments) < 2 {
+		t.Fatalf("expected several segments, got %d", len(store.segments))
+	}
+
+	records, err := store.Query("a/http", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecordTimes(t, records, from, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+
+	records, err = store.Query("a/http", from.Add(3*time.Minute), from.Add(6*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecordTimes(t, records, from, 3, 4, 5, 6)
+
+	records, err = store.Tail("a/http", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecordTimes(t, records, from, 6, 7, 8, 9)
+
+	records, err = store.Tail("b/http", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecordTimes(t, records, from, 0, 1, 2)
+
+	records, err = store.Tail("missing", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 0 {
+		t.Fatalf("got %d records for an unknown job", len(records))
+	}
+}
+
+func TestLogStoreRecoversDamagedSegment(t *testing.T) {
+	dir := t.TempDir()
+	from := time.Now().Add(-time.Hour).Truncate(time.Minute)
+
+	store := openTestLogStore(t, dir, 24*time.Hour, 1<<20)
+	appendTestRecords(t, store, "a/http", from, 3)
+
+	path := store.segments[0].path
+	size := store.segments[0].size
+
+	err := store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash in the middle of a write leaves a partial record behind.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.Write([]byte{0, 0, 0, 42, 1, 2, 3, 4, '{', '"'})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file.Close()
+
+	store = openTestLogStore(t, dir, 24*time.Hour, 1<<20)
+	defer store.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != size {
+		t.Fatalf("segment is %d bytes after recovery, want %d", info.Size(), size)
+	}
+
+	err = store.Append(ResultRecord{Job: "a/http", At: from.Add(3 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.Tail("a/http", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecordTimes(t, records, from, 0, 1, 2, 3)
+}
+
+func TestLogStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// Every result gets its own segment.
+	store := openTestLogStore(t, dir, time.Hour, 1)
+	defer store.Close()
+
+	appendTestRecords(t, store, "a/http", now.Add(-3*time.Hour), 3)
+
+	// A query that is still reading keeps its segment open.
+	pinned := store.pin(store.segments[0], store.segments[0].index["a/http"])
+
+	appendTestRecords(t, store, "a/http", now.Add(-time.Minute), 1)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 1 || len(store.segments) != 1 {
+		t.Fatalf("got %d files and %d segments after prune, want 1", len(paths), len(store.segments))
+	}
+
+	records, err := store.read([]segmentRead{pinned})
+	if err != nil {
+		t.Fatalf("read from a pruned segment: %v", err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("got %d records from the pruned segment, want 1", len(records))
+	}
+
+	records, err = store.Query("a/http", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecordTimes(t, records, now.Add(-time.Minute), 0)
+}
+
+func TestLogStoreQueryWhileAppending(t *testing.T) {
+	from := time.Now().Add(-time.Hour).Truncate(time.Minute)
+
+	store := openTestLogStore(t, t.TempDir(), 24*time.Hour, 1024)
+	defer store.Close()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 50; i++ {
+			err := store.Append(ResultRecord{Job: "a/http", At: from.Add(time.Duration(i) * time.Minute)})
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		_, err := store.Query("a/http", time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := store.Query("a/http", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 50 {
+		t.Fatalf("got %d records, want 50", len(records))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultRetention       = 30 * 24 * time.Hour
+	defaultSegmentSize     = 16 << 20
+	defaultSegmentDuration = 24 * time.Hour
+
+	// restoreResults is how many stored results are replayed per job on
+	// startup, unless its flap window and thresholds need more.
+	restoreResults = 100
+)
+
+// ResultRecord is a check result as it is stored. At is when the check
+// started, Maintenance names the maintenance window the job was in and
+// Incident the incident the result belongs to.
+type ResultRecord struct {
+	Job         string             `json:"job"`
+	At          time.Time          `json:"at"`
+	Duration    time.Duration      `json:"duration"`
+	Success     bool               `json:"success"`
+	Severity    Severity           `json:"severity"`
+	Message     string             `json:"message,omitempty"`
+	Metrics     map[string]float64 `json:"metrics,omitempty"`
+	Maintenance string             `json:"maintenance,omitempty"`
+	Incident    int64              `json:"incident,omitempty"`
+}
+
+func (r ResultRecord) Result() CheckResult {
+	return CheckResult{
+		Success:  r.Success,
+		Severity: r.Severity,
+		Message:  r.Message,
+		Duration: r.Duration,
+		Metrics:  r.Metrics,
+	}
+}
+
+// ResultStore keeps the history of check results. Implementations apply
+// their retention on their own and must be safe for concurrent use.
+type ResultStore interface {
+	Append(record ResultRecord) error
+
+	// Query returns the results of job between from and to, oldest first.
+	// A zero from or to leaves that end open.
+	Query(job string, from, to time.Time) ([]ResultRecord, error)
+
+	// Tail returns the last n results of job, oldest first.
+	Tail(job string, n int) ([]ResultRecord, error)
+
+	Close() error
+}
+
+// NewResultStore opens the result store configured in config. The log
+// backend keeps its segments in the results directory below dataDir.
+func NewResultStore(log *slog.Logger, config ResultsConfig, dataDir string) (ResultStore, error) {
+	switch config.Backend {
+	case "", "log":
+		segmentSize := config.SegmentSize
+		if segmentSize <= 0 {
+			segmentSize = defaultSegmentSize
+		}
+
+		segmentDuration := config.SegmentDuration
+		if segmentDuration <= 0 {
+			segmentDuration = defaultSegmentDuration
+		}
+
+		return OpenLogStore(log, filepath.Join(dataDir, "results"), resultRetention(config), segmentSize, segmentDuration)
+	case "none":
+		return discardStore{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown results backend %q", config.Backend)
+}
+
+// resultRetention returns how long results are kept. Resolved incidents are
+// kept as long, so stored results never refer to a missing incident.
+func resultRetention(config ResultsConfig) time.Duration {
+	if config.Retention > 0 {
+		return config.Retention
+	}
+
+	return defaultRetention
+}
+
+// discardStore drops all results. Jobs start without state.
+type discardStore struct{}
+
+func (discardStore) Append(ResultRecord) error {
+	return nil
+}
+
+func (discardStore) Query(string, time.Time, time.Time) ([]ResultRecord, error) {
+	return []ResultRecord{}, nil
+}
+
+func (discardStore) Tail(string, int) ([]ResultRecord, error) {
+	return []ResultRecord{}, nil
+}
+
+func (discardStore) Close() error {
+	return nil
+}
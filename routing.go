@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var groupLabels = []string{"domain", "check", "job", "severity", "state"}
+
+// routeNode is a compiled route with the inherited settings filled in.
+type routeNode struct {
+	Route
+
+	id            string
+	severities    []Severity
+	minSeverity   Severity
+	groupWait     time.Duration
+	groupInterval time.Duration
+	children      []*routeNode
+}
+
+// eventGroup collects the events of a route that share the group labels
+// until they are sent.
+type eventGroup struct {
+	route  *routeNode
+	key    string
+	labels map[string]string
+	events []Event
+	timer  *time.Timer
+}
+
+// compileRoutes compiles the route tree of config. It returns nil if no
+// route is configured.
+func compileRoutes(config Config) (*routeNode, error) {
+	if config.Route == nil {
+		return nil, nil
+	}
+
+	names := map[string]bool{}
+	for _, notifierConfig := range config.Notifiers {
+		names[notifierConfig.Name] = true
+	}
+
+	policies := map[string]bool{}
+	for _, policy := range config.EscalationPolicies {
+		policies[policy.Name] = true
+	}
+
+	return compileRoute(*config.Route, nil, "route", names, policies)
+}
+
+func compileRoute(route Route, parent *routeNode, id string, names map[string]bool, policies map[string]bool) (*routeNode, error) {
+	node := &routeNode{
+		Route: route,
+		id:    id,
+	}
+
+	if parent != nil {
+		if len(node.Notifiers) == 0 {
+			node.Notifiers = parent.Notifiers
+		}
+
+		if len(node.GroupBy) == 0 {
+			node.GroupBy = parent.GroupBy
+		}
+
+		if node.Escalation == "" {
+			node.Escalation = parent.Escalation
+		}
+
+		node.groupWait = parent.groupWait
+		node.groupInterval = parent.groupInterval
+	}
+
+	if route.GroupWait != nil {
+		node.groupWait = *route.GroupWait
+	}
+
+	if route.GroupInterval != nil {
+		node.groupInterval = *route.GroupInterval
+	}
+
+	for _, name := range route.Notifiers {
+		if !names[name] {
+			return nil, fmt.Errorf("%s: unknown notifier %s", id, name)
+		}
+	}
+
+	if route.Escalation != "" && !policies[route.Escalation] {
+		return nil, fmt.Errorf("%s: unknown escalation policy %s", id, route.Escalation)
+	}
+
+	for _, label := range route.GroupBy {
+		if !slices.Contains(groupLabels, label) {
+			return nil, fmt.Errorf("%s: can't group by %q, must be one of %s", id, label, strings.Join(groupLabels, ", "))
+		}
+	}
+
+	for _, name := range route.Severities {
+		severity, err := parseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+
+		node.severities = append(node.severities, severity)
+	}
+
+	if route.MinSeverity != "" {
+		severity, err := parseSeverity(route.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+
+		node.minSeverity = severity
+	}
+
+	for i, child := range route.Routes {
+		childNode, err := compileRoute(child, node, fmt.Sprintf("%s.routes[%d]", id, i), names, policies)
+		if err != nil {
+			return nil, err
+		}
+
+		node.children = append(node.children, childNode)
+	}
+
+	return node, nil
+}
+
+func (n *routeNode) matches(event Event) bool {
+	if len(n.Domains) > 0 && !slices.Contains(n.Domains, event.Domain) && (event.DomainKey == "" || !slices.Contains(n.Domains, event.DomainKey)) {
+		return false
+	}
+
+	if len(n.Checks) > 0 && !slices.Contains(n.Checks, event.Check) {
+		return false
+	}
+
+	for _, tag := range n.Tags {
+		if !slices.Contains(event.Tags, tag) {
+			return false
+		}
+	}
+
+	if len(n.severities) > 0 && !slices.Contains(n.severities, event.Severity) {
+		return false
+	}
+
+	return event.Severity >= n.minSeverity
+}
+
+// match returns the routes that receive the event: the deepest matching
+// routes below n, or n itself if no child matches.
+func (n *routeNode) match(event Event) []*routeNode {
+	matched := []*routeNode{}
+
+	for _, child := range n.children {
+		if !child.matches(event) {
+			continue
+		}
+
+		matched = append(matched, child.match(event)...)
+
+		if !child.Continue {
+			break
+		}
+	}
+
+	if len(matched) == 0 {
+		return []*routeNode{n}
+	}
+
+	return matched
+}
+
+// group returns the labels of event for the GroupBy of the route, and a key
+// that is unique per route and labels.
+func (n *routeNode) group(event Event) (string, map[string]string) {
+	labels := map[string]string{}
+	key := n.id
+
+	for _, label := range n.GroupBy {
+		var value string
+
+		switch label {
+		case "domain":
+			value = event.Domain
+		case "check":
+			value = event.Check
+		case "job":
+			value = event.Job
+		case "severity":
+			value = event.Severity.String()
+		case "state":
+			value = event.To
+		}
+
+		labels[label] = value
+		key += "\x00" + label + "=" + value
+	}
+
+	return key, labels
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteMatch(t *testing.T) {
+	config := Config{
+		Notifiers: []NotifierConfig{{Name: "default"}, {Name: "db"}, {Name: "pager"}, {Name: "audit"}},
+		Route: &Route{
+			Notifiers: []string{"default"},
+			Routes: []Route{
+				{
+					Tags:     []string{"audit"},
+					Continue: true,
+					Routes: []Route{
+						{Notifiers: []string{"audit"}},
+					},
+				},
+				{
+					Domains:   []string{"db"},
+					Notifiers: []string{"db"},
+					Routes: []Route{
+						{MinSeverity: "down", Notifiers: []string{"pager"}},
+					},
+				},
+				{
+					Checks:    []string{"ssl"},
+					Notifiers: []string{"pager"},
+				},
+			},
+		},
+	}
+
+	root, err := compileRoutes(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{
+			name:  "no child matches",
+			event: Event{Domain: "web.example.com", Check: "http", Severity: SeverityError},
+			want:  "route",
+		},
+		{
+			name:  "domain key",
+			event: Event{Domain: "db.example.com", DomainKey: "db", Check: "dns", Severity: SeverityError},
+			want:  "route.routes[1]",
+		},
+		{
+			name:  "deepest match",
+			event: Event{Domain: "db.example.com", DomainKey: "db", Check: "dns", Severity: SeverityDown},
+			want:  "route.routes[1].routes[0]",
+		},
+		{
+			name:  "first match stops",
+			event: Event{Domain: "db.example.com", DomainKey: "db", Check: "ssl", Severity: SeverityError},
+			want:  "route.routes[1]",
+		},
+		{
+			name:  "continue goes on to the next sibling",
+			event: Event{Domain: "db.example.com", DomainKey: "db", Check: "dns", Severity: SeverityError, Tags: []string{"audit"}},
+			want:  "route.routes[0].routes[0],route.routes[1]",
+		},
+		{
+			name:  "continue without a later match",
+			event: Event{Domain: "web.example.com", Check: "http", Severity: SeverityError, Tags: []string{"audit"}},
+			want:  "route.routes[0].routes[0]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids := []string{}
+			for _, node := range root.match(test.event) {
+				ids = append(ids, node.id)
+			}
+
+			if got := strings.Join(ids, ","); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompileRoutesInherits(t *testing.T) {
+	config := Config{
+		Notifiers: []NotifierConfig{{Name: "default"}},
+		Route: &Route{
+			Notifiers: []string{"default"},
+			GroupBy:   []string{"domain"},
+			Routes: []Route{
+				{Checks: []string{"ssl"}},
+			},
+		},
+	}
+
+	root, err := compileRoutes(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child := root.children[0]
+	if strings.Join(child.Notifiers, ",") != "default" || strings.Join(child.GroupBy, ",") != "domain" {
+		t.Fatalf("child did not inherit notifiers and grouping: %v %v", child.Notifiers, child.GroupBy)
+	}
+
+	config.Route.Routes[0].Notifiers = []string{"missing"}
+
+	_, err = compileRoutes(config)
+	if err == nil {
+		t.Fatal("expected an error for an unknown notifier")
+	}
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency   = 10
+	defaultShutdownGrace = 10 * time.Second
+
+	// storeFailureLimit is the number of results in a row the result store
+	// may fail to append before the scheduler gives up.
+	storeFailureLimit = 10
+)
+
+var (
+	errNotRunning = errors.New("scheduler is not running")
+	errNotFound   = errors.New("not found")
+)
+
+type jobResult struct {
+	job     *Job
+	result  CheckResult
+	started time.Time
+}
+
+type Scheduler struct {
+	log *slog.Logger
+
+	dispatcher *Dispatcher
+	incidents  *IncidentStore
+	store      ResultStore
+	sla        *SLA
+
+	jobs            []*Job
+	byKey           map[string]*Job
+	maintenance     []*Maintenance
+	runtimeWindows  []*Maintenance
+	concurrency     int
+	shutdownGrace   time.Duration
+	quarantineRetry time.Duration
+
+	pending jobQueue
+	ready   []*Job
+
+	storeFailures int
+
+	work     chan *Job
+	results  chan jobResult
+	inFlight int
+
+	calls chan func()
+	fatal chan error
+	done  chan struct{}
+	err   error
+}
+
+func NewScheduler(log *slog.Logger, config Config, jobs []*Job, dispatcher *Dispatcher, incidents *IncidentStore, results ResultStore) (*Scheduler, error) {
+	log = log.With("service", "Scheduler")
+
+	maintenance, err := compileMaintenance(config)
+	if err != nil {
+		return nil, err
+	}
+
+	sla, err := compileSLA(config.SLA)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	shutdownGrace := config.ShutdownGrace
+	if shutdownGrace <= 0 {
+		shutdownGrace = defaultShutdownGrace
+	}
+
+	scheduler := &Scheduler{
+		log: log,
+
+		dispatcher: dispatcher,
+		incidents:  incidents,
+		store:      results,
+		sla:        sla,
+
+		jobs:            jobs,
+		byKey:           jobsByKey(jobs),
+		maintenance:     maintenance,
+		runtimeWindows:  []*Maintenance{},
+		concurrency:     concurrency,
+		shutdownGrace:   shutdownGrace,
+		quarantineRetry: config.QuarantineRetry,
+
+		pending: newJobQueue(jobs),
+		ready:   []*Job{},
+
+		work:    make(chan *Job, concurrency),
+		results: make(chan jobResult, concurrency),
+
+		calls: make(chan func()),
+		fatal: make(chan error, 1),
+		done:  make(chan struct{}),
+	}
+
+	for _, job := range jobs {
+		scheduler.restore(job)
+	}
+
+	return scheduler, nil
+}
+
+// restore rebuilds the state of a job from its stored results.
+func (s *Scheduler) restore(job *Job) {
+	records, err := s.store.Tail(job.Key(), job.restoreWindow())
+	if err != nil {
+		s.log.Error("Failed to restore job state", "name", job.check.Name, "domain", job.domain.Domain, "error", err)
+
+		return
+	}
+
+	if len(records) == 0 {
+		return
+	}
+
+	job.restore(records)
+
+	s.log.Info("Job state restored", "name", job.check.Name, "domain", job.domain.Domain, "state", job.State(), "since", job.stateSince, "results", len(records))
+}
+
+// Do runs fn on the scheduler loop, where it may safely read and change jobs.
+// It returns false if the scheduler is no longer running.
+func (s *Scheduler) Do(fn func()) bool {
+	finished := make(chan struct{})
+
+	select {
+	case s.calls <- func() {
+		defer close(finished)
+
+		fn()
+	}:
+	case <-s.done:
+		return false
+	}
+
+	<-finished
+
+	return true
+}
+
+// Fail stops the scheduler because of an error in the core itself, like a
+// result store that keeps failing. Check results never stop the scheduler,
+// they only quarantine their job.
+func (s *Scheduler) Fail(err error) {
+	select {
+	case s.fatal <- err:
+	default:
+	}
+}
+
+// Reload replaces the scheduled jobs and maintenance windows of config. Jobs
+// whose config did not change keep running on their current schedule, and
+// windows added at runtime are kept.
+func (s *Scheduler) Reload(config Config, jobs []*Job) error {
+	maintenance, err := compileMaintenance(config)
+	if err != nil {
+		return err
+	}
+
+	sla, err := compileSLA(config.SLA)
+	if err != nil {
+		return err
+	}
+
+	ok := s.Do(func() {
+		s.maintenance = maintenance
+		s.sla = sla
+		s.apply(jobs)
+	})
+	if !ok {
+		return errNotRunning
+	}
+
+	return nil
+}
+
+// Run schedules jobs until ctx is done or the scheduler fails, then waits for
+// running checks before returning. It returns the error passed to Fail.
+func (s *Scheduler) Run(ctx context.Context) error {
+	// Checks get their own context so that they can finish during the
+	// shutdown grace period after ctx is done.
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+
+	workers := sync.WaitGroup{}
+	for i := 0; i < s.concurrency; i++ {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			s.worker(runCtx)
+		}()
+	}
+
+	s.loop(ctx)
+	s.drain(cancelRuns)
+
+	workers.Wait()
+
+	return s.err
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Info("Stopping scheduler...")
+
+			return
+		case <-timer.C:
+			now := time.Now()
+
+			for next := s.pending.peek(); next != nil && !next.next.After(now); next = s.pending.peek() {
+				job := heap.Pop(&s.pending).(*Job)
+
+				if s.suppress(job) || s.pause(job, now) {
+					job.next = job.nextRun(now)
+
+					heap.Push(&s.pending, job)
+
+					continue
+				}
+
+				job.running = true
+
+				s.ready = append(s.ready, job)
+			}
+
+			s.dispatch()
+		case res := <-s.results:
+			s.inFlight--
+			res.job.running = false
+
+			if res.job.removed {
+				s.release(res.job)
+				s.dispatch()
+
+				break
+			}
+
+			s.handleResult(res)
+
+			s.dispatch()
+		case err := <-s.fatal:
+			s.log.Error("Scheduler failed", "error", err)
+
+			s.err = err
+
+			return
+		case fn := <-s.calls:
+			fn()
+
+			s.dispatch()
+		}
+
+		s.resetTimer(timer)
+	}
+}
+
+// drain stops dispatching and waits for running checks. Checks still running
+// after the shutdown grace period are cancelled and their results dropped.
+func (s *Scheduler) drain(cancelRuns context.CancelFunc) {
+	close(s.work)
+	s.ready = nil
+
+	if s.inFlight > 0 {
+		s.log.Info("Waiting for running checks", "count", s.inFlight, "grace", s.shutdownGrace)
+	}
+
+	grace := time.NewTimer(s.shutdownGrace)
+	defer grace.Stop()
+
+	cancelled := false
+
+	for s.inFlight > 0 {
+		select {
+		case res := <-s.results:
+			s.inFlight--
+			res.job.running = false
+
+			if !cancelled && !res.job.removed {
+				s.handleResult(res)
+			}
+		case <-grace.C:
+			s.log.Warn("Shutdown grace period expired. Cancelling running checks", "count", s.inFlight)
+
+			cancelRuns()
+			cancelled = true
+		}
+	}
+}
+
+func (s *Scheduler) apply(jobs []*Job) {
+	old := map[string]*Job{}
+	for _, job := range s.jobs {
+		old[job.Key()] = job
+	}
+
+	next := []*Job{}
+	for _, job := range jobs {
+		prev, ok := old[job.Key()]
+		delete(old, job.Key())
+
+		if ok && prev.sameConfig(job) {
+			prev.dependsOn = job.dependsOn
+
+			next = append(next, prev)
+
+			if prev.quarantined {
+				s.log.Info("Job released from quarantine", "name", prev.check.Name, "domain", prev.domain.Domain)
+
+				prev.release()
+				prev.next = time.Now()
+
+				if prev.index >= 0 {
+					heap.Fix(&s.pending, prev.index)
+				} else if !prev.running {
+					heap.Push(&s.pending, prev)
+				}
+			}
+
+			continue
+		}
+
+		if ok {
+			s.log.Info("Updating job", "name", job.check.Name, "domain", job.domain.Domain)
+
+			s.remove(prev)
+			job.inherit(prev)
+
+			if prev.running {
+				prev.replacedBy = job
+				job.running = true
+
+				next = append(next, job)
+
+				continue
+			}
+		} else {
+			s.log.Info("Adding job", "name", job.check.Name, "domain", job.domain.Domain, "args", job.checkConfig.Args)
+
+			s.restore(job)
+		}
+
+		heap.Push(&s.pending, job)
+
+		next = append(next, job)
+	}
+
+	for _, job := range old {
+		s.log.Info("Removing job", "name", job.check.Name, "domain", job.domain.Domain)
+
+		s.remove(job)
+		s.incidents.Close(job.Key(), "job removed from config")
+	}
+
+	s.jobs = next
+	s.byKey = jobsByKey(next)
+}
+
+// release queues the job held back by a reload once the job it replaced
+// comes back. Held jobs that were replaced or removed themselves in the
+// meantime pass it on.
+func (s *Scheduler) release(job *Job) {
+	next := job.replacedBy
+	for next != nil && next.removed {
+		next = next.replacedBy
+	}
+
+	if next == nil {
+		return
+	}
+
+	next.running = false
+
+	heap.Push(&s.pending, next)
+}
+
+func jobsByKey(jobs []*Job) map[string]*Job {
+	byKey := map[string]*Job{}
+	for _, job := range jobs {
+		byKey[job.Key()] = job
+	}
+
+	return byKey
+}
+
+// suppress reports whether the job should be skipped because a job it
+// depends on is down, or is suppressed itself.
+func (s *Scheduler) suppress(job *Job) bool {
+	var down *Job
+	for _, key := range job.dependsOn {
+		parent, ok := s.byKey[key]
+		if ok && (parent.state == StateDown || parent.suppressed) {
+			down = parent
+
+			break
+		}
+	}
+
+	if down != nil && !job.suppressed {
+		s.log.Info("Job suppressed", "name", job.check.Name, "domain", job.domain.Domain, "parent", down.Key())
+	}
+
+	if down == nil && job.suppressed {
+		s.log.Info("Job no longer suppressed", "name", job.check.Name, "domain", job.domain.Domain)
+	}
+
+	job.suppressed = down != nil
+
+	return job.suppressed
+}
+
+// remove takes a job out of the queue. Jobs that are waiting for a worker or
+// running are only marked, and dropped once they come back.
+func (s *Scheduler) remove(job *Job) {
+	job.removed = true
+
+	if job.index >= 0 {
+		heap.Remove(&s.pending, job.index)
+	}
+}
+
+// pause records the maintenance window the job is in and reports whether
+// the window pauses it.
+func (s *Scheduler) pause(job *Job, now time.Time) bool {
+	var window *Maintenance
+	for _, m := range slices.Concat(s.maintenance, s.runtimeWindows) {
+		if m.Matches(job) && m.Active(now) {
+			window = m
+
+			break
+		}
+	}
+
+	name := ""
+	if window != nil {
+		name = window.Name
+	}
+
+	if name != job.maintenance {
+		if name != "" {
+			s.log.Info("Job entered maintenance", "name", job.check.Name, "domain", job.domain.Domain, "maintenance", name, "mode", window.Mode)
+		} else {
+			s.log.Info("Job left maintenance", "name", job.check.Name, "domain", job.domain.Domain, "maintenance", job.maintenance)
+		}
+	}
+
+	job.maintenance = name
+
+	return window != nil && window.Mode == MaintenancePause
+}
+
+// resetTimer arms the timer for the earliest pending job. With nothing
+// pending the timer stays stopped until a running job is pushed back.
+func (s *Scheduler) resetTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	next := s.pending.peek()
+	if next == nil {
+		return
+	}
+
+	timer.Reset(time.Until(next.next))
+}
+
+// dispatch hands ready jobs to the workers without ever exceeding the
+// concurrency limit, so sending on the queue never blocks the loop.
+func (s *Scheduler) dispatch() {
+	for len(s.ready) > 0 && s.inFlight < s.concurrency {
+		job := s.ready[0]
+		s.ready = s.ready[1:]
+
+		if job.removed {
+			job.running = false
+			s.release(job)
+
+			continue
+		}
+
+		s.inFlight++
+		s.work <- job
+	}
+}
+
+func (s *Scheduler) worker(ctx context.Context) {
+	for job := range s.work {
+		s.log.Info("Running check", "name", job.check.Name, "domain", job.domain.Domain)
+
+		timeout := job.timeout()
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+
+		started := time.Now()
+		result := job.check.run(runCtx, job.domain.Domain, job.checkConfig.Args)
+		result.Duration = time.Since(started)
+
+		cancel()
+
+		if result.TimedOut {
+			result.Message = fmt.Sprintf("check timed out after %s", timeout)
+		}
+
+		s.results <- jobResult{
+			job:     job,
+			result:  result,
+			started: started,
+		}
+	}
+}
+
+// handleResult updates the job state and puts the job back in the queue.
+// Jobs that report a fatal result are quarantined instead.
+func (s *Scheduler) handleResult(res jobResult) {
+	job := res.job
+	result := res.result
+
+	s.log.Debug("Check result", "name", job.check.Name, "domain", job.domain.Domain, "success", result.Success, "severity", result.Severity, "timed_out", result.TimedOut, "duration", result.Duration, "message", result.Message, "metrics", result.Metrics)
+
+	job.lastRun = res.started
+	job.lastResult = result
+
+	record := ResultRecord{
+		Job:      job.Key(),
+		At:       res.started,
+		Duration: result.Duration,
+		Success:  result.Success,
+		Severity: result.Severity,
+		Message:  result.Message,
+		Metrics:  result.Metrics,
+
+		Maintenance: job.maintenance,
+	}
+
+	if !result.Success && result.Severity == SeverityFatal {
+		s.storeResult(job, record)
+		s.quarantine(job, result.Message)
+
+		if s.quarantineRetry > 0 {
+			job.next = res.started.Add(s.quarantineRetry)
+
+			heap.Push(&s.pending, job)
+		}
+
+		return
+	}
+
+	if job.quarantined {
+		s.log.Info("Job released from quarantine", "name", job.check.Name, "domain", job.domain.Domain)
+
+		job.release()
+	}
+
+	now := time.Now()
+
+	change, changed := job.observe(result, now)
+	if changed {
+		change.Maintenance = job.maintenance
+		change.Incident = s.incidents.Observe(job, result, &change, now)
+		record.Incident = change.Incident
+
+		s.storeResult(job, record)
+		s.emit(job, change)
+	} else {
+		record.Incident = s.incidents.Observe(job, result, nil, now)
+
+		s.storeResult(job, record)
+	}
+
+	job.next = job.nextRun(res.started)
+
+	heap.Push(&s.pending, job)
+}
+
+// storeResult appends the result to the result store. The scheduler fails
+// once the store keeps failing.
+func (s *Scheduler) storeResult(job *Job, record ResultRecord) {
+	err := s.store.Append(record)
+	if err != nil {
+		s.log.Error("Failed to store result", "name", job.check.Name, "domain", job.domain.Domain, "error", err)
+
+		s.storeFailures++
+		if s.storeFailures >= storeFailureLimit {
+			s.Fail(fmt.Errorf("result store failed %d times in a row: %w", s.storeFailures, err))
+		}
+
+		return
+	}
+
+	s.storeFailures = 0
+}
+
+// quarantine disables a job that reported a fatal result. It stays disabled
+// until the next config reload, or until a retry after quarantine_retry no
+// longer fails fatally.
+func (s *Scheduler) quarantine(job *Job, reason string) {
+	if !job.quarantined {
+		job.quarantined = true
+		job.quarantinedAt = time.Now()
+	}
+
+	job.quarantineReason = reason
+
+	s.log.Error("Job quarantined", "name", job.check.Name, "domain", job.domain.Domain, "reason", reason, "retry", s.quarantineRetry)
+}
+
+func (s *Scheduler) emit(job *Job, change StateChange) {
+	attrs := []any{"name", job.check.Name, "domain", job.domain.Domain, "from", change.From, "to", change.To, "message", change.Result.Message}
+
+	if change.Maintenance != "" {
+		s.log.Info("State changed during maintenance", append(attrs, "maintenance", change.Maintenance)...)
+
+		// The change is not alerted, but a recovery still ends the
+		// escalations of an outage that started before the window.
+		s.dispatcher.SendMuted(newEvent(job, change))
+
+		return
+	}
+
+	switch change.To {
+	case StateDown:
+		s.log.Error("State changed", attrs...)
+	case StateDegraded, StateFlapping:
+		s.log.Warn("State changed", attrs...)
+	default:
+		s.log.Info("State changed", attrs...)
+	}
+
+	event := newEvent(job, change)
+
+	if change.To == StateUp {
+		event.Severity = job.alertSeverity
+	} else {
+		job.alertSeverity = event.Severity
+	}
+
+	s.dispatcher.Send(event)
+}
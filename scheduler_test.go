@@ -0,0 +1,228 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var (
+	benchmarkSizes     = []int{1_000, 10_000, 50_000}
+	benchmarkIntervals = []time.Duration{time.Minute, time.Hour}
+)
+
+// benchmarkJobs returns n jobs whose first runs are spread over their
+// interval, so n/interval of them are due every second.
+func benchmarkJobs(n int, interval time.Duration) []*Job {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	jobs := make([]*Job, n)
+	for i := range jobs {
+		jobs[i] = &Job{
+			checkConfig: CheckConfig{Interval: interval},
+			next:        start.Add(time.Duration(i) * interval / time.Duration(n)),
+			index:       -1,
+		}
+	}
+
+	return jobs
+}
+
+// benchmarkTick runs tick once per second of simulated time for every job
+// count and interval. tick returns the number of jobs it found due.
+func benchmarkTick(b *testing.B, setup func([]*Job) func(now time.Time) int) {
+	for _, interval := range benchmarkIntervals {
+		for _, n := range benchmarkSizes {
+			b.Run(fmt.Sprintf("interval=%s/jobs=%d", interval, n), func(b *testing.B) {
+				jobs := benchmarkJobs(n, interval)
+				tick := setup(jobs)
+				now := jobs[0].next
+				due := 0
+
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					now = now.Add(time.Second)
+					due += tick(now)
+				}
+
+				b.ReportMetric(float64(due)/float64(b.N), "due/op")
+			})
+		}
+	}
+}
+
+// BenchmarkTickerScan measures one tick of the loop the scheduler had before
+// the queue: every second it walked all jobs to find the due ones.
+func BenchmarkTickerScan(b *testing.B) {
+	benchmarkTick(b, func(jobs []*Job) func(time.Time) int {
+		return func(now time.Time) int {
+			due := 0
+
+			for _, job := range jobs {
+				if job.running || !job.next.Before(now) {
+					continue
+				}
+
+				job.next = job.next.Add(job.interval())
+				due++
+			}
+
+			return due
+		}
+	})
+}
+
+// BenchmarkJobQueue measures the same tick on the queue: only the due jobs
+// are popped and pushed back.
+func BenchmarkJobQueue(b *testing.B) {
+	benchmarkTick(b, func(jobs []*Job) func(time.Time) int {
+		queue := newJobQueue(jobs)
+
+		return func(now time.Time) int {
+			due := 0
+
+			for next := queue.peek(); next != nil && next.next.Before(now); next = queue.peek() {
+				job := heap.Pop(&queue).(*Job)
+
+				job.next = job.next.Add(job.interval())
+				due++
+
+				heap.Push(&queue, job)
+			}
+
+			return due
+		}
+	})
+}
+
+func testJob(t *testing.T, interval time.Duration) *Job {
+	t.Helper()
+
+	job, err := NewJob(Domain{Domain: "example.com"}, Check{Name: "Test"}, CheckConfig{Key: "test", Interval: interval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return job
+}
+
+// testScheduler returns a scheduler that is not running, with a dispatcher
+// that is not running either, so sent events stay in its queue.
+func testScheduler(t *testing.T, config Config, store ResultStore, jobs ...*Job) *Scheduler {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	dispatcher, err := NewDispatcher(log, config, map[string]Notifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incidents, err := NewIncidentStore(log, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewScheduler(log, config, jobs, dispatcher, incidents, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// queuedEvents takes the events the scheduler sent to its dispatcher so far.
+func queuedEvents(s *Scheduler) []queuedEvent {
+	events := []queuedEvent{}
+
+	for {
+		select {
+		case event := <-s.dispatcher.events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSchedulerApplyHoldsRunningReplacement(t *testing.T) {
+	prev := testJob(t, time.Minute)
+	prev.running = true
+
+	s := &Scheduler{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store: discardStore{},
+		jobs:  []*Job{prev},
+	}
+
+	first := testJob(t, 2*time.Minute)
+	s.apply([]*Job{first})
+
+	if s.pending.Len() != 0 {
+		t.Fatal("replacement queued while the job it replaces is running")
+	}
+
+	// A second reload before the first job is back replaces the held job.
+	second := testJob(t, 3*time.Minute)
+	s.apply([]*Job{second})
+
+	if s.pending.Len() != 0 {
+		t.Fatal("replacement queued while the job it replaces is running")
+	}
+
+	prev.running = false
+	s.release(prev)
+
+	if s.pending.peek() != second || second.running {
+		t.Fatal("latest replacement not queued once the running job came back")
+	}
+
+	if first.index >= 0 {
+		t.Fatal("replaced held job was queued")
+	}
+}
+
+type failingStore struct {
+	discardStore
+}
+
+func (failingStore) Append(ResultRecord) error {
+	return errors.New("disk full")
+}
+
+func TestSchedulerFailsOnPersistentStoreErrors(t *testing.T) {
+	s := &Scheduler{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store: failingStore{},
+		fatal: make(chan error, 1),
+	}
+
+	job := testJob(t, time.Minute)
+	result := jobResult{
+		job:    job,
+		result: CheckResult{Success: false, Severity: SeverityFatal},
+	}
+
+	for i := 1; i < storeFailureLimit; i++ {
+		s.handleResult(result)
+	}
+
+	select {
+	case err := <-s.fatal:
+		t.Fatalf("failed before the limit: %v", err)
+	default:
+	}
+
+	s.handleResult(result)
+
+	select {
+	case <-s.fatal:
+	default:
+		t.Fatal("persistent store errors did not fail the scheduler")
+	}
+}
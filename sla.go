@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CountUp     = "up"
+	CountDown   = "down"
+	CountIgnore = "ignore"
+
+	windowMonth = "month"
+)
+
+var defaultSLAWindows = []string{"24h", "7d", "30d", windowMonth}
+
+// defaultSLAPolicy says how failed results count by severity. Successful
+// results always count as up.
+var defaultSLAPolicy = map[Severity]string{
+	SeverityDebug:   CountUp,
+	SeverityNotice:  CountUp,
+	SeverityWarning: CountUp,
+	SeverityError:   CountDown,
+	SeverityDown:    CountDown,
+	SeverityFatal:   CountIgnore,
+}
+
+// SLA computes the availability of jobs from their stored results. Each
+// result stands for the time until the next result of its job, but at most
+// until the next regular run. Failing jobs that run on a shorter failure
+// interval therefore don't weigh more. The availability is the share of the
+// counted time that counts as up.
+type SLA struct {
+	windows            []slaWindow
+	policy             map[Severity]string
+	includeMaintenance bool
+	location           *time.Location
+}
+
+// slaWindow is a rolling window of a fixed length, or the calendar month so
+// far if length is 0.
+type slaWindow struct {
+	name   string
+	length time.Duration
+}
+
+// Availability is the availability of a job or domain over one window. Up,
+// Down and Excluded count the results that cover part of the window, the
+// seconds fields hold the time they cover. Percent is nil if no time was
+// counted.
+type Availability struct {
+	Window          string    `json:"window"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Up              int       `json:"up"`
+	Down            int       `json:"down"`
+	Excluded        int       `json:"excluded"`
+	UpSeconds       float64   `json:"up_seconds"`
+	DownSeconds     float64   `json:"down_seconds"`
+	ExcludedSeconds float64   `json:"excluded_seconds"`
+	Percent         *float64  `json:"percent"`
+
+	up       time.Duration
+	down     time.Duration
+	excluded time.Duration
+}
+
+type JobAvailability struct {
+	Job     string         `json:"job"`
+	Check   string         `json:"check"`
+	Windows []Availability `json:"windows"`
+}
+
+type DomainAvailability struct {
+	Domain  string            `json:"domain"`
+	Windows []Availability    `json:"windows"`
+	Jobs    []JobAvailability `json:"jobs"`
+}
+
+func compileSLA(config SLAConfig) (*SLA, error) {
+	sla := &SLA{
+		policy:             map[Severity]string{},
+		includeMaintenance: config.IncludeMaintenance,
+		location:           time.Local,
+	}
+
+	if config.Timezone != "" {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("sla: %w", err)
+		}
+
+		sla.location = location
+	}
+
+	names := config.Windows
+	if len(names) == 0 {
+		names = defaultSLAWindows
+	}
+
+	for _, name := range names {
+		window, err := parseSLAWindow(name)
+		if err != nil {
+			return nil, fmt.Errorf("sla: %w", err)
+		}
+
+		sla.windows = append(sla.windows, window)
+	}
+
+	for severity, count := range defaultSLAPolicy {
+		sla.policy[severity] = count
+	}
+
+	for name, count := range config.Policy {
+		severity, err := parseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("sla: policy: %w", err)
+		}
+
+		if count != CountUp && count != CountDown && count != CountIgnore {
+			return nil, fmt.Errorf("sla: policy: %s must count as %s, %s or %s", name, CountUp, CountDown, CountIgnore)
+		}
+
+		sla.policy[severity] = count
+	}
+
+	return sla, nil
+}
+
+// parseSLAWindow parses "month" or a duration, which may also be given in
+// days like "7d".
+func parseSLAWindow(name string) (slaWindow, error) {
+	if name == windowMonth {
+		return slaWindow{name: name}, nil
+	}
+
+	var length time.Duration
+
+	if days, ok := strings.CutSuffix(name, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return slaWindow{}, fmt.Errorf("invalid window %q", name)
+		}
+
+		length = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+
+		length, err = time.ParseDuration(name)
+		if err != nil {
+			return slaWindow{}, fmt.Errorf("invalid window %q", name)
+		}
+	}
+
+	if length <= 0 {
+		return slaWindow{}, fmt.Errorf("window %q must be positive", name)
+	}
+
+	return slaWindow{name: name, length: length}, nil
+}
+
+func (s *SLA) start(window slaWindow, now time.Time) time.Time {
+	if window.length > 0 {
+		return now.Add(-window.length)
+	}
+
+	local := now.In(s.location)
+
+	return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, s.location)
+}
+
+// count says whether a result counts as up or down, or is ignored.
+func (s *SLA) count(record ResultRecord) string {
+	if record.Maintenance != "" && !s.includeMaintenance {
+		return CountIgnore
+	}
+
+	if record.Success {
+		return CountUp
+	}
+
+	return s.policy[record.Severity]
+}
+
+// Compute returns the availability of the jobs over the windows, grouped by
+// domain in the order the jobs are given. If names is not empty those
+// windows are computed instead of the configured ones.
+func (s *SLA) Compute(store ResultStore, jobs []*Job, names []string, now time.Time) ([]DomainAvailability, error) {
+	windows := s.windows
+
+	if len(names) > 0 {
+		windows = []slaWindow{}
+
+		for _, name := range names {
+			window, err := parseSLAWindow(name)
+			if err != nil {
+				return nil, err
+			}
+
+			windows = append(windows, window)
+		}
+	}
+
+	from := now
+	for _, window := range windows {
+		from = minTime(from, s.start(window, now))
+	}
+
+	domains := []DomainAvailability{}
+	byDomain := map[string]int{}
+
+	for _, job := range jobs {
+		// The last result before the windows may still cover their start.
+		records, err := store.Query(job.Key(), from.Add(-job.span(from)), now)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := byDomain[job.domain.Domain]
+		if !ok {
+			i = len(domains)
+			byDomain[job.domain.Domain] = i
+
+			domain := DomainAvailability{
+				Domain: job.domain.Domain,
+				Jobs:   []JobAvailability{},
+			}
+
+			for _, window := range windows {
+				domain.Windows = append(domain.Windows, Availability{Window: window.name, From: s.start(window, now), To: now})
+			}
+
+			domains = append(domains, domain)
+		}
+
+		domain := &domains[i]
+
+		jobAvailability := JobAvailability{
+			Job:   job.Key(),
+			Check: job.checkConfig.Key,
+		}
+
+		for j, window := range windows {
+			availability := Availability{
+				Window: window.name,
+				From:   s.start(window, now),
+				To:     now,
+			}
+
+			for k, record := range records {
+				// A result stands until the next one, unless runs were
+				// missed. The last one stands for the expected gap.
+				end := record.At.Add(job.expectedSpan(record.At))
+				if k+1 < len(records) {
+					end = minTime(record.At.Add(job.span(record.At)), records[k+1].At)
+				}
+
+				end = minTime(end, now)
+
+				start := record.At
+				if start.Before(availability.From) {
+					start = availability.From
+				}
+
+				if !end.After(start) {
+					continue
+				}
+
+				availability.add(s.count(record), end.Sub(start))
+			}
+
+			availability.finish()
+
+			total := &domain.Windows[j]
+			total.Up += availability.Up
+			total.Down += availability.Down
+			total.Excluded += availability.Excluded
+			total.up += availability.up
+			total.down += availability.down
+			total.excluded += availability.excluded
+			total.finish()
+
+			jobAvailability.Windows = append(jobAvailability.Windows, availability)
+		}
+
+		domain.Jobs = append(domain.Jobs, jobAvailability)
+	}
+
+	return domains, nil
+}
+
+// add counts a result that covers d of the window.
+func (a *Availability) add(count string, d time.Duration) {
+	switch count {
+	case CountUp:
+		a.Up++
+		a.up += d
+	case CountDown:
+		a.Down++
+		a.down += d
+	default:
+		a.Excluded++
+		a.excluded += d
+	}
+}
+
+// finish fills in the seconds and the percentage from the counted time.
+func (a *Availability) finish() {
+	a.UpSeconds = a.up.Seconds()
+	a.DownSeconds = a.down.Seconds()
+	a.ExcludedSeconds = a.excluded.Seconds()
+	a.Percent = nil
+
+	if a.up+a.down > 0 {
+		value := 100 * float64(a.up) / float64(a.up+a.down)
+		a.Percent = &value
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+
+	return a
+}
+
+// Availability computes the availability of the current jobs, optionally
+// only those of one domain.
+func (s *Scheduler) Availability(domain string, windows []string, now time.Time) ([]DomainAvailability, error) {
+	var sla *SLA
+	var jobs []*Job
+
+	ok := s.Do(func() {
+		sla = s.sla
+
+		for _, job := range s.jobs {
+			if domain == "" || job.domain.Domain == domain || job.domain.Key == domain {
+				jobs = append(jobs, job)
+			}
+		}
+	})
+	if !ok {
+		return nil, errNotRunning
+	}
+
+	if domain != "" && len(jobs) == 0 {
+		return nil, fmt.Errorf("domain %s: %w", domain, errNotFound)
+	}
+
+	return sla.Compute(s.store, jobs, windows, now)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps the results of one job in memory, oldest first.
+type memoryStore struct {
+	discardStore
+
+	records []ResultRecord
+}
+
+func (m *memoryStore) Append(record ResultRecord) error {
+	m.records = append(m.records, record)
+
+	return nil
+}
+
+func (m *memoryStore) Query(job string, from, to time.Time) ([]ResultRecord, error) {
+	records := []ResultRecord{}
+	for _, record := range m.records {
+		if record.Job == job && !record.At.Before(from) && !record.At.After(to) {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func TestSLAWeighsResultsByTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	job, err := NewJob(Domain{Domain: "example.com"}, Check{}, CheckConfig{
+		Key:             "http",
+		Interval:        5 * time.Minute,
+		FailureInterval: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memoryStore{}
+	add := func(at time.Time, success bool) {
+		store.records = append(store.records, ResultRecord{Job: job.Key(), At: at, Success: success, Severity: SeverityDown})
+	}
+
+	// A 10 minute outage in the middle of a day checked every 5 minutes,
+	// and every 10 seconds while failing.
+	outage := now.Add(-12 * time.Hour)
+
+	for at := now.Add(-24 * time.Hour); at.Before(outage); at = at.Add(5 * time.Minute) {
+		add(at, true)
+	}
+
+	for at := outage; at.Before(outage.Add(10 * time.Minute)); at = at.Add(10 * time.Second) {
+		add(at, false)
+	}
+
+	for at := outage.Add(10 * time.Minute); at.Before(now); at = at.Add(5 * time.Minute) {
+		add(at, true)
+	}
+
+	sla, err := compileSLA(SLAConfig{Windows: []string{"24h", "1h"}, Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains, err := sla.Compute(store, []*Job{job}, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := domains[0].Jobs[0].Windows[0]
+
+	want := 100 * (1 - 10.0/(24*60))
+	if day.Percent == nil || math.Abs(*day.Percent-want) > 1e-9 {
+		t.Fatalf("got %v%%, want %v%%", day.Percent, want)
+	}
+
+	if day.DownSeconds != 600 || day.UpSeconds+day.DownSeconds != 24*60*60 {
+		t.Fatalf("got %vs up and %vs down", day.UpSeconds, day.DownSeconds)
+	}
+
+	if domains[0].Windows[0].Percent == nil || *domains[0].Windows[0].Percent != *day.Percent {
+		t.Fatal("domain total differs from its only job")
+	}
+
+	hour := domains[0].Jobs[0].Windows[1]
+	if hour.Percent == nil || *hour.Percent != 100 || hour.UpSeconds != 3600 {
+		t.Fatalf("got %v%% and %vs up over the last hour", hour.Percent, hour.UpSeconds)
+	}
+}
+
+func TestSLAWeighsResultsByJitter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	job, err := NewJob(Domain{Domain: "example.com"}, Check{}, CheckConfig{
+		Key:      "http",
+		Interval: time.Minute,
+		Jitter:   40 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memoryStore{}
+	add := func(at time.Time, success bool) {
+		store.records = append(store.records, ResultRecord{Job: job.Key(), At: at, Success: success, Severity: SeverityDown})
+	}
+
+	// Delayed by the full jitter, the next result still follows without a
+	// hole. A missed run leaves one. The last result stands for the interval
+	// and the average jitter.
+	add(now.Add(-10*time.Minute), true)
+	add(now.Add(-10*time.Minute+100*time.Second), true)
+	add(now.Add(-5*time.Minute), false)
+
+	sla, err := compileSLA(SLAConfig{Windows: []string{"1h"}, Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains, err := sla.Compute(store, []*Job{job}, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hour := domains[0].Jobs[0].Windows[0]
+	if hour.UpSeconds != 200 || hour.DownSeconds != 80 {
+		t.Fatalf("got %vs up and %vs down", hour.UpSeconds, hour.DownSeconds)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import "time"
+
+const defaultFlapWindow = 21
+
+type State int
+
+const (
+	StateUnknown State = iota
+	StateUp
+	StateDegraded
+	StateDown
+	StateFlapping
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUp:
+		return "UP"
+	case StateDegraded:
+		return "DEGRADED"
+	case StateDown:
+		return "DOWN"
+	case StateFlapping:
+		return "FLAPPING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// resultState maps a check result onto the state it points the job towards.
+func resultState(result CheckResult) State {
+	if result.Success {
+		return StateUp
+	}
+
+	if result.Severity >= SeverityError {
+		return StateDown
+	}
+
+	return StateDegraded
+}
+
+// StateChange is emitted whenever a job moves from one state to another.
+type StateChange struct {
+	From   State
+	To     State
+	Since  time.Time
+	At     time.Time
+	Result CheckResult
+
+	// Maintenance names the maintenance window the job was in, if any.
+	// Changes during maintenance are recorded but not alerted.
+	Maintenance string
+
+	// Incident is the ID of the incident the change belongs to, or 0.
+	Incident int64
+}
+
+// State returns the state the job reports to the outside, which is FLAPPING
+// while flap detection holds back its transitions.
+func (j *Job) State() State {
+	if j.flapping {
+		return StateFlapping
+	}
+
+	return j.state
+}
+
+// observe records a result and reports a state change once enough
+// consecutive results agree on a new state. While the job is flapping the
+// underlying state keeps changing but no transitions are reported.
+func (j *Job) observe(result CheckResult, at time.Time) (StateChange, bool) {
+	from, since := j.State(), j.stateSince
+	if j.flapping {
+		since = j.flappingSince
+	}
+
+	change, changed := j.transition(result, at)
+
+	high := j.checkConfig.FlapThresholdHigh
+	if high <= 0 {
+		return change, changed
+	}
+
+	j.history = append(j.history, resultState(result))
+	if len(j.history) > j.flapWindow() {
+		j.history = j.history[1:]
+	}
+
+	percent := flapPercent(j.history)
+
+	if !j.flapping && percent > high {
+		j.flapping = true
+		j.flappingSince = at
+
+		return StateChange{
+			From:   from,
+			To:     StateFlapping,
+			Since:  since,
+			At:     at,
+			Result: result,
+		}, true
+	}
+
+	if j.flapping && percent < j.flapThresholdLow() {
+		j.flapping = false
+
+		return StateChange{
+			From:   StateFlapping,
+			To:     j.state,
+			Since:  since,
+			At:     at,
+			Result: result,
+		}, true
+	}
+
+	if j.flapping {
+		return StateChange{}, false
+	}
+
+	return change, changed
+}
+
+func (j *Job) transition(result CheckResult, at time.Time) (StateChange, bool) {
+	target := resultState(result)
+	threshold := j.successThreshold()
+
+	if result.Success {
+		j.successes++
+		j.failures = 0
+	} else {
+		j.failures++
+		j.successes = 0
+
+		threshold = j.failureThreshold()
+	}
+
+	if target == j.streakState {
+		j.streak++
+	} else {
+		j.streakState = target
+		j.streak = 1
+	}
+
+	j.lastResult = result
+
+	if target == j.state || j.streak < threshold {
+		return StateChange{}, false
+	}
+
+	change := StateChange{
+		From:   j.state,
+		To:     target,
+		Since:  j.stateSince,
+		At:     at,
+		Result: result,
+	}
+
+	j.state = target
+	j.stateSince = at
+
+	return change, true
+}
+
+// restore replays stored results to rebuild the state of the job after a
+// restart. The state changes are not reported again.
+func (j *Job) restore(records []ResultRecord) {
+	for _, record := range records {
+		result := record.Result()
+		j.lastRun = record.At
+
+		if !result.Success && result.Severity == SeverityFatal {
+			continue
+		}
+
+		change, changed := j.observe(result, record.At)
+		if changed && change.To != StateUp {
+			j.alertSeverity = result.Severity
+		}
+	}
+}
+
+// restoreWindow is the number of results restore needs to rebuild the
+// thresholds and the flap history.
+func (j *Job) restoreWindow() int {
+	return max(restoreResults, j.flapWindow()+max(j.failureThreshold(), j.successThreshold()))
+}
+
+func (j *Job) failureThreshold() int {
+	return max(j.checkConfig.FailureThreshold, 1)
+}
+
+func (j *Job) successThreshold() int {
+	return max(j.checkConfig.SuccessThreshold, 1)
+}
+
+// flapPercent returns the weighted percentage of state changes in history.
+// Like Nagios, recent changes weigh more than old ones, from 0.8 for the
+// oldest to 1.2 for the newest.
+func flapPercent(history []State) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+
+	transitions := len(history) - 1
+
+	changes := 0.0
+	for i := 1; i < len(history); i++ {
+		if history[i] == history[i-1] {
+			continue
+		}
+
+		weight := 1.0
+		if transitions > 1 {
+			weight = 0.8 + 0.4*float64(i-1)/float64(transitions-1)
+		}
+
+		changes += weight
+	}
+
+	return changes / float64(transitions) * 100
+}
+
+func (j *Job) flapWindow() int {
+	if j.checkConfig.FlapWindow > 1 {
+		return j.checkConfig.FlapWindow
+	}
+
+	return defaultFlapWindow
+}
+
+// flapThresholdLow defaults to two thirds of the high threshold, which
+// matches the 20/30 defaults of Nagios.
+func (j *Job) flapThresholdLow() float64 {
+	if j.checkConfig.FlapThresholdLow > 0 {
+		return j.checkConfig.FlapThresholdLow
+	}
+
+	return j.checkConfig.FlapThresholdHigh * 2 / 3
+}
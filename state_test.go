@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testResults turns a string like "UWED" into results: U succeeds, W, E and D
+// fail with a warning, error and down severity.
+func testResults(t *testing.T, results string) []CheckResult {
+	t.Helper()
+
+	severities := map[rune]Severity{
+		'W': SeverityWarning,
+		'E': SeverityError,
+		'D': SeverityDown,
+	}
+
+	out := []CheckResult{}
+	for _, r := range results {
+		if r == 'U' {
+			out = append(out, CheckResult{Success: true})
+
+			continue
+		}
+
+		severity, ok := severities[r]
+		if !ok {
+			t.Fatalf("unknown result %q", r)
+		}
+
+		out = append(out, CheckResult{Success: false, Severity: severity})
+	}
+
+	return out
+}
+
+func TestJobTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		results string
+		want    []State
+	}{
+		{
+			name:    "threshold reached",
+			results: "UUUEEE",
+			want:    []State{StateUp, StateDown},
+		},
+		{
+			name:    "success resets the failures",
+			results: "UUUEEUEE",
+			want:    []State{StateUp},
+		},
+		{
+			name:    "degraded needs its own confirmation before down",
+			results: "WWWE",
+			want:    []State{StateDegraded},
+		},
+		{
+			name:    "mixed failures confirm nothing",
+			results: "UUUWWE",
+			want:    []State{StateUp},
+		},
+		{
+			name:    "degraded then down",
+			results: "WWWEEE",
+			want:    []State{StateDegraded, StateDown},
+		},
+		{
+			name:    "error and down point the same way",
+			results: "UUUEDE",
+			want:    []State{StateUp, StateDown},
+		},
+		{
+			name:    "recovery uses the success threshold",
+			results: "EEEUU",
+			want:    []State{StateDown, StateUp},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &Job{
+				checkConfig: CheckConfig{
+					FailureThreshold: 3,
+					SuccessThreshold: 2,
+				},
+			}
+
+			at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			got := []State{}
+			for _, result := range testResults(t, test.results) {
+				at = at.Add(time.Minute)
+
+				change, changed := job.observe(result, at)
+				if changed {
+					got = append(got, change.To)
+				}
+			}
+
+			if stateNames(got) != stateNames(test.want) {
+				t.Fatalf("got %s, want %s", stateNames(got), stateNames(test.want))
+			}
+		})
+	}
+}
+
+func stateNames(states []State) string {
+	names := make([]string, len(states))
+	for i, state := range states {
+		names[i] = state.String()
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestFlapPercent(t *testing.T) {
+	U, D := StateUp, StateDown
+
+	tests := []struct {
+		name    string
+		history []State
+		want    float64
+	}{
+		{"empty", nil, 0},
+		{"single", []State{U}, 0},
+		{"steady", []State{U, U, U, U, U}, 0},
+		{"one change", []State{U, D}, 100},
+		{"always changing", []State{U, D, U, D, U}, 100},
+		{"oldest change weighs least", []State{U, D, D, D, D}, 20},
+		{"newest change weighs most", []State{U, U, U, U, D}, 30},
+		{"middle change", []State{U, U, D, D, D}, 1.0 / 4 * 100 * (0.8 + 0.4/3)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := flapPercent(test.history)
+			if math.Abs(got-test.want) > 1e-9 {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJobFlapping(t *testing.T) {
+	job := &Job{
+		checkConfig: CheckConfig{
+			FlapWindow:        5,
+			FlapThresholdHigh: 50,
+			FlapThresholdLow:  25,
+		},
+	}
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got := []State{}
+	for _, result := range testResults(t, "UEUEUUUUUU") {
+		at = at.Add(time.Minute)
+
+		change, changed := job.observe(result, at)
+		if changed {
+			got = append(got, change.To)
+		}
+	}
+
+	// The change to DOWN that starts the flapping is reported as FLAPPING.
+	want := []State{StateUp, StateFlapping, StateUp}
+	if stateNames(got) != stateNames(want) {
+		t.Fatalf("got %s, want %s", stateNames(got), stateNames(want))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import "time"
+
+// JobStatus is a snapshot of a job taken on the scheduler loop.
+type JobStatus struct {
+	Job       string `json:"job"`
+	Domain    string `json:"domain"`
+	DomainKey string `json:"domain_key,omitempty"`
+	Check     string `json:"check"`
+	CheckName string `json:"check_name"`
+
+	State      string    `json:"state"`
+	StateSince time.Time `json:"state_since"`
+
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+	ConsecutiveFailures  int `json:"consecutive_failures"`
+
+	// LastResult is nil until the job ran once.
+	LastResult *ResultRecord `json:"last_result,omitempty"`
+	NextRun    time.Time     `json:"next_run"`
+	Running    bool          `json:"running"`
+
+	Suppressed       bool   `json:"suppressed"`
+	Maintenance      string `json:"maintenance,omitempty"`
+	Quarantined      bool   `json:"quarantined"`
+	QuarantineReason string `json:"quarantine_reason,omitempty"`
+
+	domain Domain
+	config CheckConfig
+}
+
+// SchedulerStatus is a snapshot of the scheduler and its jobs. QueueLag is
+// how long the oldest job waiting for a worker is overdue.
+type SchedulerStatus struct {
+	Jobs     []JobStatus
+	Running  int
+	Waiting  int
+	QueueLag time.Duration
+}
+
+func (j *Job) status() JobStatus {
+	status := JobStatus{
+		Job:       j.Key(),
+		Domain:    j.domain.Domain,
+		DomainKey: j.domain.Key,
+		Check:     j.checkConfig.Key,
+		CheckName: j.check.Name,
+
+		State:      j.State().String(),
+		StateSince: j.stateSince,
+
+		ConsecutiveSuccesses: j.successes,
+		ConsecutiveFailures:  j.failures,
+
+		NextRun: j.next,
+		Running: j.running,
+
+		Suppressed:       j.suppressed,
+		Maintenance:      j.maintenance,
+		Quarantined:      j.quarantined,
+		QuarantineReason: j.quarantineReason,
+
+		domain: j.domain,
+		config: j.checkConfig,
+	}
+
+	if j.flapping {
+		status.StateSince = j.flappingSince
+	}
+
+	if !j.lastRun.IsZero() {
+		status.LastResult = &ResultRecord{
+			Job:      status.Job,
+			At:       j.lastRun,
+			Duration: j.lastResult.Duration,
+			Success:  j.lastResult.Success,
+			Severity: j.lastResult.Severity,
+			Message:  j.lastResult.Message,
+			Metrics:  j.lastResult.Metrics,
+
+			Maintenance: j.maintenance,
+		}
+	}
+
+	return status
+}
+
+// Status takes a snapshot of the scheduler. It returns false if the
+// scheduler is no longer running.
+func (s *Scheduler) Status(now time.Time) (SchedulerStatus, bool) {
+	status := SchedulerStatus{}
+
+	ok := s.Do(func() {
+		status.Jobs = make([]JobStatus, 0, len(s.jobs))
+		for _, job := range s.jobs {
+			status.Jobs = append(status.Jobs, job.status())
+		}
+
+		status.Running = s.inFlight
+		status.Waiting = len(s.ready)
+
+		for _, job := range s.ready {
+			status.QueueLag = max(status.QueueLag, now.Sub(job.next))
+		}
+	})
+
+	return status, ok
+}
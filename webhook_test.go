@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookStub answers the requests it gets with the given status codes in
+// turn, and 200 once they run out.
+type webhookStub struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+
+	received chan struct{}
+}
+
+func newWebhookStub(t *testing.T, statuses ...int) (*webhookStub, *httptest.Server) {
+	stub := &webhookStub{
+		statuses: statuses,
+		received: make(chan struct{}, 16),
+	}
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	return stub, server
+}
+
+func (s *webhookStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+
+	status := http.StatusOK
+	if len(s.requests) < len(s.statuses) {
+		status = s.statuses[len(s.requests)]
+	}
+
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	s.times = append(s.times, time.Now())
+
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+
+	s.received <- struct{}{}
+}
+
+func (s *webhookStub) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.requests)
+}
+
+var webhookNotification = Notification{
+	Receiver: "test",
+	Events: []Event{{
+		Job:      "example.com/http",
+		Domain:   "example.com",
+		Check:    "http",
+		From:     "UP",
+		To:       "DOWN",
+		Severity: SeverityDown,
+		Message:  "status code is not as expected: 500",
+		At:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}},
+}
+
+func TestWebhookSignsBody(t *testing.T) {
+	app := stdApp(t)
+	stub, server := newWebhookStub(t)
+
+	notifier := stdNotifier(t, app, "webhook", map[string]string{
+		"url":     server.URL,
+		"secret":  "s3cret",
+		"headers": "X-Team: ops\nX-Env: prod",
+	})
+
+	err := notifier.Notify(context.Background(), webhookNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, body := stub.requests[0], stub.bodies[0]
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+
+	if got, want := req.Header.Get("X-Uptime-Gopher-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Fatalf("got signature %q, want %q", got, want)
+	}
+
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("X-Team") != "ops" || req.Header.Get("X-Env") != "prod" {
+		t.Fatalf("got %s with headers %v", req.Method, req.Header)
+	}
+
+	var payload struct {
+		Receiver string `json:"receiver"`
+		Events   []struct {
+			Job      string `json:"job"`
+			To       string `json:"to"`
+			Severity string `json:"severity"`
+		} `json:"events"`
+	}
+
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Receiver != "test" || len(payload.Events) != 1 || payload.Events[0].Job != "example.com/http" || payload.Events[0].To != "DOWN" || payload.Events[0].Severity != "DOWN" {
+		t.Fatalf("got payload %s", body)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	app := stdApp(t)
+
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		fails    bool
+	}{
+		{"server error is retried", []int{500, 502}, 3, false},
+		{"timeout and rate limit are retried", []int{408, 429}, 3, false},
+		{"client error is not retried", []int{404}, 1, true},
+		{"bad request is not retried", []int{400}, 1, true},
+		{"retries run out", []int{503, 503, 503, 503}, 4, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub, server := newWebhookStub(t, test.statuses...)
+
+			notifier := stdNotifier(t, app, "webhook", map[string]string{
+				"url":     server.URL,
+				"retries": "3",
+				"backoff": "10ms",
+			})
+
+			err := notifier.Notify(context.Background(), webhookNotification)
+			if test.fails != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, test.fails)
+			}
+
+			if stub.attempts() != test.attempts {
+				t.Fatalf("got %d attempts, want %d", stub.attempts(), test.attempts)
+			}
+
+			// The backoff doubles after every attempt.
+			backoff := 10 * time.Millisecond
+
+			for i := 1; i < len(stub.times); i++ {
+				if delay := stub.times[i].Sub(stub.times[i-1]); delay < backoff {
+					t.Fatalf("retry %d after %s, want at least %s", i, delay, backoff)
+				}
+
+				backoff *= 2
+			}
+		})
+	}
+}
+
+func TestWebhookCancelledDuringBackoff(t *testing.T) {
+	app := stdApp(t)
+	stub, server := newWebhookStub(t, 500)
+
+	notifier := stdNotifier(t, app, "webhook", map[string]string{
+		"url":     server.URL,
+		"backoff": "1h",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-stub.received
+		cancel()
+	}()
+
+	started := time.Now()
+
+	err := notifier.Notify(ctx, webhookNotification)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the cancellation", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("cancelled notification returned after %s", elapsed)
+	}
+
+	if stub.attempts() != 1 {
+		t.Fatalf("got %d attempts, want 1", stub.attempts())
+	}
+}